@@ -0,0 +1,339 @@
+package funny
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AssertEq assertEq(actual, expected, message) raise error with the differences if actual
+// does not equal expected, dicts and lists are compared item by item
+func AssertEq(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	if lines := diffValues(fn, "$", args[1], args[0], false); len(lines) > 0 {
+		assertionFailed(fn, "assertEq", args, 2, "values are not equal", diffText(lines))
+	}
+	return nil
+}
+
+// AssertNe assertNe(actual, expected, message) raise error if actual equals expected
+func AssertNe(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	if len(diffValues(fn, "$", args[1], args[0], false)) == 0 {
+		assertionFailed(fn, "assertNe", args, 2, fmt.Sprintf("values are equal: %s", render(args[0])), "")
+	}
+	return nil
+}
+
+// AssertContains assertContains(container, item, message) raise error if the string does not
+// contain the substring, the list does not contain the item or the dict does not have the key
+func AssertContains(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	found := false
+	switch c := args[0].(type) {
+	case string:
+		s, ok := args[1].(string)
+		if !ok {
+			panic(P(fmt.Sprintf("assertContains type error, string can only contain [string] given [%s]", Typing(args[1])), fn.Current))
+		}
+		found = strings.Contains(c, s)
+	case []interface{}:
+		for _, item := range c {
+			if len(diffValues(fn, "$", args[1], item, false)) == 0 {
+				found = true
+				break
+			}
+		}
+	case map[string]Value, map[string]interface{}:
+		key, ok := args[1].(string)
+		if !ok {
+			panic(P(fmt.Sprintf("assertContains type error, dict keys are [string] given [%s]", Typing(args[1])), fn.Current))
+		}
+		dict, _ := asDict(c)
+		_, found = dict[key]
+	default:
+		panic(P(fmt.Sprintf("assertContains type error, only support [string, list, dict] given [%s]", Typing(args[0])), fn.Current))
+	}
+	if !found {
+		assertionFailed(fn, "assertContains", args, 2, fmt.Sprintf("%s does not contain %s", render(args[0]), render(args[1])), "")
+	}
+	return nil
+}
+
+// AssertMatch assertMatch(regex, s, message) raise error if s does not match the regular
+// expression
+func AssertMatch(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	pattern, ok := args[0].(string)
+	if !ok {
+		panic(P(fmt.Sprintf("assertMatch type error, regex only support [string] given [%s]", Typing(args[0])), fn.Current))
+	}
+	s, ok := args[1].(string)
+	if !ok {
+		panic(P(fmt.Sprintf("assertMatch type error, only support [string] given [%s]", Typing(args[1])), fn.Current))
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		panic(P(err.Error(), fn.Current))
+	}
+	if !re.MatchString(s) {
+		assertionFailed(fn, "assertMatch", args, 2, fmt.Sprintf("%s does not match /%s/", render(s), pattern), "")
+	}
+	return nil
+}
+
+// AssertType assertType(value, type, message) raise error if value is not of type, which is
+// one of nil, bool, int, float, string, list, dict, function and time, or given by typeof
+func AssertType(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	expected, ok := args[1].(string)
+	if !ok {
+		panic(P(fmt.Sprintf("assertType type error, type only support [string] given [%s]", Typing(args[1])), fn.Current))
+	}
+	actual := typeName(args[0])
+	if expected != actual && expected != Typing(args[0]) {
+		assertionFailed(fn, "assertType", args, 2, fmt.Sprintf("type of %s is %s, not %s", render(args[0]), actual, expected), "")
+	}
+	return nil
+}
+
+// AssertThrows assertThrows(fn, message) raise error if calling fn does not raise one, the
+// error raised is returned like the one caught by try catch
+func AssertThrows(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 1, 2)
+	if !IsCallable(args[0]) {
+		panic(P(fmt.Sprintf("assertThrows type error, only support [function] given [%s]", Typing(args[0])), fn.Current))
+	}
+	current, call := fn.Current, fn.CurrentCall
+	fre := callGuarded(fn, args[0])
+	fn.Current, fn.CurrentCall = current, call
+	if fre == nil {
+		assertionFailed(fn, "assertThrows", args, 1, "no error was raised", "")
+	}
+	return Value(fre.Dict())
+}
+
+// AssertJsonSubset assertJsonSubset(actual, expected, message) raise error if actual does not
+// have all the keys and list items of expected, strings of JSON are parsed first. Lists are
+// compared by index and may have more items than the expected ones
+func AssertJsonSubset(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 2, 3)
+	if lines := diffValues(fn, "$", parseJSON(args[1]), parseJSON(args[0]), true); len(lines) > 0 {
+		assertionFailed(fn, "assertJsonSubset", args, 2, "value is not a superset of expected", diffText(lines))
+	}
+	return nil
+}
+
+// assertionFailed raise the error of assertion name like assertEq(a, b) failed: reason,
+// followed by the message at args[at] if given and detail
+func assertionFailed(fn *Funny, name string, args []Value, at int, reason, detail string) {
+	call := name + "()"
+	if c := fn.CurrentCall; c != nil && c.Position == fn.Current {
+		call = callString(c)
+	}
+	msg := fmt.Sprintf("%s failed: %s", call, reason)
+	if len(args) > at {
+		msg += fmt.Sprintf(": %v", args[at])
+	}
+	if detail != "" {
+		msg += "\n" + detail
+	}
+	panic(P(msg, fn.Current))
+}
+
+// callString the source of call on one line, arguments of many lines like functions and
+// dicts are shortened to their first and last lines
+func callString(call *FunctionCall) string {
+	var args []string
+	for _, item := range call.Parameters {
+		s := item.String()
+		if block, ok := item.(*Block); ok {
+			s = block.Format(false)
+		}
+		lines := strings.Split(strings.TrimSpace(s), "\n")
+		arg := strings.TrimSpace(lines[0])
+		if len(lines) > 1 {
+			arg += " ... " + strings.TrimSpace(lines[len(lines)-1])
+		}
+		args = append(args, arg)
+	}
+	return fmt.Sprintf("%s(%s)", call.Name, strings.Join(args, ", "))
+}
+
+// callGuarded call function f without arguments and recover the error raised by it
+func callGuarded(fn *Funny, f Value) (fre *FunnyRuntimeError) {
+	vars, stack := fn.Vars, len(fn.Stack)
+	defer func() {
+		if e := recover(); e != nil {
+			fre = fn.RuntimeError(e)
+			fn.Vars = vars
+			fn.Stack = fn.Stack[:stack]
+		}
+	}()
+	fn.CallFunction(f, nil)
+	return nil
+}
+
+// diffValues the differences of actual from expected at path, like - $.name: "a" for the
+// expected value and + $.name: "b" for the actual one. Keys and items actual has but
+// expected does not are ignored if subset is true
+func diffValues(fn *Funny, path string, expected, actual Value, subset bool) []string {
+	if e, ok := asDict(expected); ok {
+		if a, ok := asDict(actual); ok {
+			var lines []string
+			for _, key := range unionKeys(e, a, subset) {
+				at := path + "." + key
+				ev, eok := e[key]
+				av, aok := a[key]
+				switch {
+				case !aok:
+					lines = append(lines, fmt.Sprintf("- %s: %s", at, render(ev)))
+				case !eok:
+					lines = append(lines, fmt.Sprintf("+ %s: %s", at, render(av)))
+				default:
+					lines = append(lines, diffValues(fn, at, ev, av, subset)...)
+				}
+			}
+			return lines
+		}
+	}
+	if e, ok := expected.([]interface{}); ok {
+		if a, ok := actual.([]interface{}); ok {
+			var lines []string
+			for index := 0; index < len(e) || index < len(a) && !subset; index++ {
+				at := fmt.Sprintf("%s[%d]", path, index)
+				switch {
+				case index >= len(a):
+					lines = append(lines, fmt.Sprintf("- %s: %s", at, render(e[index])))
+				case index >= len(e):
+					lines = append(lines, fmt.Sprintf("+ %s: %s", at, render(a[index])))
+				default:
+					lines = append(lines, diffValues(fn, at, e[index], a[index], subset)...)
+				}
+			}
+			return lines
+		}
+	}
+	if scalarEqual(fn, expected, actual) {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("- %s: %s", path, render(expected)),
+		fmt.Sprintf("+ %s: %s", path, render(actual)),
+	}
+}
+
+// diffText the diff lines under the header saying which side is which
+func diffText(lines []string) string {
+	return "--- expected\n+++ actual\n" + strings.Join(lines, "\n")
+}
+
+// unionKeys the keys of expected, and of actual unless only expected ones are wanted,
+// in sorted order
+func unionKeys(expected, actual map[string]Value, onlyExpected bool) []string {
+	seen := make(map[string]bool)
+	for key := range expected {
+		seen[key] = true
+	}
+	if !onlyExpected {
+		for key := range actual {
+			seen[key] = true
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalarEqual whether two values which are not dicts or lists are equal, numbers are
+// compared like == does so 1 equals 1.0
+func scalarEqual(fn *Funny, expected, actual Value) bool {
+	if c, ok := fn.Compare(expected, actual); ok {
+		return c == 0
+	}
+	if e, ok := expected.(time.Time); ok {
+		a, ok := actual.(time.Time)
+		return ok && e.Equal(a)
+	}
+	if reflect.TypeOf(expected) != reflect.TypeOf(actual) {
+		return false
+	}
+	if expected == nil || reflect.TypeOf(expected).Comparable() {
+		return expected == actual
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+// asDict v as map[string]Value if it is a dict
+func asDict(v Value) (map[string]Value, bool) {
+	switch d := v.(type) {
+	case map[string]Value:
+		return d, true
+	case map[string]interface{}:
+		dict := make(map[string]Value, len(d))
+		for key, val := range d {
+			dict[key] = val
+		}
+		return dict, true
+	}
+	return nil, false
+}
+
+// parseJSON the value of v if it is a string of a JSON object or array, otherwise v
+func parseJSON(v Value) Value {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return v
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(trimmed), &data); err != nil {
+		return v
+	}
+	return Value(data)
+}
+
+// render v as JSON for messages, or as printed by echo if it can not be
+func render(v Value) string {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bts)
+}
+
+// typeName the name of the type of v in funny
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]Value, map[string]interface{}:
+		return "dict"
+	case time.Time:
+		return "time"
+	}
+	if IsCallable(v) {
+		return "function"
+	}
+	return Typing(v)
+}
@@ -0,0 +1,74 @@
+package funny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runAssertion run code and return the message of the error it raises
+func runAssertion(code string) string {
+	_, err := NewFunny().Run(code)
+	if err == nil {
+		return ""
+	}
+	return err.(*FunnyRuntimeError).Msg
+}
+
+func TestAssertionsPass(t *testing.T) {
+	assert.Equal(t, "", runAssertion(`
+add(a, b) {
+  return a + b
+}
+assertEq(add(1, 2), 3.0)
+assertEq([1, 'a'], [1, 'a'])
+assertNe(add(1, 2), 4)
+assertContains('hello', 'ell')
+assertContains([1, [2]], [2])
+assertMatch('^h.l', 'hello')
+assertType(1.5, 'float')
+assertType(add, 'function')
+err = assertThrows(fn() {
+  throw('boom')
+})
+assertEq(err.message, 'boom')
+assertJsonSubset('{"a": 1, "b": [1, 2, {"c": 3, "d": 4}]}', {
+  b = [1, 2, {
+    c = 3
+  }]
+})
+`))
+}
+
+func TestAssertEqDiff(t *testing.T) {
+	msg := runAssertion(`
+got = {
+  name = 'alice'
+  tags = [1, 2]
+  age = 3
+}
+assertEq(got, {
+  name = 'bob'
+  tags = [1, 2, 3]
+}, 'users differ')
+`)
+	assert.Equal(t, `assertEq(got, { ... }, 'users differ') failed: values are not equal: users differ
+--- expected
++++ actual
++ $.age: 3
+- $.name: "bob"
++ $.name: "alice"
+- $.tags[2]: 3`, msg)
+
+	assert.Equal(t, "assertEq(1 + 2, 4) failed: values are not equal\n--- expected\n+++ actual\n- $: 4\n+ $: 3", runAssertion("assertEq(1 + 2, 4)"))
+}
+
+func TestAssertionsFail(t *testing.T) {
+	assert.Equal(t, `assertNe(1, 1.0) failed: values are equal: 1`, runAssertion("assertNe(1, 1.0)"))
+	assert.Equal(t, `assertContains([1, 2], 3) failed: [1,2] does not contain 3`, runAssertion("assertContains([1, 2], 3)"))
+	assert.Equal(t, `assertMatch('^a', 'bc') failed: "bc" does not match /^a/`, runAssertion("assertMatch('^a', 'bc')"))
+	assert.Equal(t, `assertType(1, 'string') failed: type of 1 is int, not string`, runAssertion("assertType(1, 'string')"))
+	assert.Equal(t, `assertThrows(fn() { ... }, 'must fail') failed: no error was raised: must fail`, runAssertion("assertThrows(fn() {\n  return 1\n}, 'must fail')"))
+	assert.Equal(t, "assertJsonSubset('{\"a\": 1}', '{\"a\": 2, \"b\": [1]}') failed: value is not a superset of expected\n--- expected\n+++ actual\n- $.a: 2\n+ $.a: 1\n- $.b: [1]",
+		runAssertion(`assertJsonSubset('{"a": 1}', '{"a": 2, "b": [1]}')`))
+}
@@ -2,6 +2,7 @@ package funny
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -42,14 +43,18 @@ const (
 	STLiteral            = "Literal"
 	STBinaryExpression   = "BinaryExpression"
 	STSubExpression      = "SubExpression"
+	STUnaryExpression    = "UnaryExpression"
 	STAssign             = "Assign"
 	STBlock              = "Block"
 	STList               = "List"
 	STListAccess         = "ListAccess"
+	STSlice              = "Slice"
+	STStringTemplate     = "StringTemplate"
 	STFunction           = "Function"
 	STFunctionCall       = "FunctionCall"
 	STImportFunctionCall = "Import"
 	STIfStatement        = "IfStatement"
+	STTryStatement       = "TryStatement"
 	STForStatement       = "ForStatement"
 	STIterableExpression = "IterableExpression"
 	STBreak              = "Break"
@@ -123,12 +128,96 @@ func (v *Literal) GetPosition() Position {
 }
 
 func (l *Literal) String() string {
-	if Typing(l.Value) == "string" {
-		return fmt.Sprintf("'%v'", l.Value)
+	switch v := l.Value.(type) {
+	case string:
+		return quoteString(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
 	}
 	return fmt.Sprintf("%v", l.Value)
 }
 
+// quoteString quote s as a string literal reads back the same value,
+// multi-line strings are quoted by three quotes
+func quoteString(s string) string {
+	if strings.Contains(s, "\n") && !strings.Contains(s, "'''") {
+		return "'''" + escapeString(s, '\'', true) + "'''"
+	}
+	return "'" + escapeString(s, '\'', false) + "'"
+}
+
+// escapeString escape quote and control chars of s, backslashes are only escaped
+// when they would be read as an escape
+func escapeString(s string, quote rune, multiLine bool) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for index, ch := range runes {
+		var next rune = -1
+		if index+1 < len(runes) {
+			next = runes[index+1]
+		}
+		switch ch {
+		case '\\':
+			if strings.ContainsRune("ntr\\'\"$", next) || next == -1 {
+				b.WriteRune('\\')
+			}
+		case quote:
+			if !multiLine || next == quote || next == -1 {
+				b.WriteRune('\\')
+			}
+		case '$':
+			if quote == '"' && next == '{' {
+				b.WriteRune('\\')
+			}
+		case '\n':
+			if !multiLine {
+				b.WriteString("\\n")
+				continue
+			}
+		case '\t':
+			b.WriteString("\\t")
+			continue
+		case '\r':
+			b.WriteString("\\r")
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// StringTemplate like "hello ${name}", parts are string literals and expressions
+type StringTemplate struct {
+	Position Position
+	Type     string
+
+	Parts []Statement
+}
+
+func (s *StringTemplate) GetPosition() Position {
+	return s.Position
+}
+
+func (s *StringTemplate) String() string {
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, part := range s.Parts {
+		if l, ok := part.(*Literal); ok {
+			if v, ok := l.Value.(string); ok {
+				b.WriteString(escapeString(v, '"', false))
+				continue
+			}
+		}
+		b.WriteString("${" + part.String() + "}")
+	}
+	b.WriteRune('"')
+	return b.String()
+}
+
 // BinaryExpression like a > 10
 type BinaryExpression struct {
 	Position Position
@@ -147,6 +236,26 @@ func (b *BinaryExpression) String() string {
 	return fmt.Sprintf("%s %s %s", b.Left.String(), b.Operator.Data, b.Right.String())
 }
 
+// UnaryExpression like not a, !a
+type UnaryExpression struct {
+	Position Position
+	Type     string
+
+	Operator   Token
+	Expression Statement
+}
+
+func (l *UnaryExpression) GetPosition() Position {
+	return l.Position
+}
+
+func (u *UnaryExpression) String() string {
+	if u.Operator.Kind == NAME {
+		return fmt.Sprintf("%s %s", u.Operator.Data, u.Expression.String())
+	}
+	return fmt.Sprintf("%s%s", u.Operator.Data, u.Expression.String())
+}
+
 // SubExpression like a = a && (b * 3), and then '(b * 3)' is SubExpression
 type SubExpression struct {
 	Position Position
@@ -211,13 +320,13 @@ func (l *List) String() string {
 	return fmt.Sprintf("[%s]", strings.Join(s, ", "))
 }
 
-// ListAccess like a[0]
+// ListAccess like a[0], a[-1], a[key] or chained access like a.b[0].c
 type ListAccess struct {
 	Position Position
 	Type     string
 
-	Index int
-	List  Variable
+	Index Statement
+	List  Statement
 }
 
 func (l *ListAccess) GetPosition() Position {
@@ -225,7 +334,38 @@ func (l *ListAccess) GetPosition() Position {
 }
 
 func (l *ListAccess) String() string {
-	return fmt.Sprintf("%s[%d]", l.List.String(), l.Index)
+	if key, ok := l.Index.(*StringExpression); ok {
+		if isName(key.Value) {
+			return fmt.Sprintf("%s.%s", l.List.String(), key.Value)
+		}
+		return fmt.Sprintf("%s['%s']", l.List.String(), key.Value)
+	}
+	return fmt.Sprintf("%s[%s]", l.List.String(), l.Index.String())
+}
+
+// Slice like a[1:3], a[:-1] or a[1:]
+type Slice struct {
+	Position Position
+	Type     string
+
+	List  Statement
+	Start Statement
+	End   Statement
+}
+
+func (s *Slice) GetPosition() Position {
+	return s.Position
+}
+
+func (s *Slice) String() string {
+	var start, end string
+	if s.Start != nil {
+		start = s.Start.String()
+	}
+	if s.End != nil {
+		end = s.End.String()
+	}
+	return fmt.Sprintf("%s[%s:%s]", s.List.String(), start, end)
 }
 
 // Block contains many statments
@@ -308,7 +448,7 @@ func (f *Function) String() string {
 		args = append(args, item.String())
 	}
 	s := f.Body.Format(false)
-	return fmt.Sprintf("%s(%s) %s", f.Name, strings.Join(args, ", "), s)
+	return fmt.Sprintf("%s(%s) %s", f.displayName(), strings.Join(args, ", "), s)
 }
 
 func (f *Function) SignatureString() string {
@@ -316,7 +456,15 @@ func (f *Function) SignatureString() string {
 	for _, item := range f.Parameters {
 		args = append(args, item.String())
 	}
-	return fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ", "))
+	return fmt.Sprintf("%s(%s)", f.displayName(), strings.Join(args, ", "))
+}
+
+// displayName anonymous functions are shown as fn
+func (f *Function) displayName() string {
+	if f.Name == "" {
+		return FN
+	}
+	return f.Name
 }
 
 // FunctionCall like test(a, b)
@@ -410,6 +558,37 @@ func (i *IFStatement) String() string {
 	}
 }
 
+// TryStatement like try {} catch err {} finally {}
+type TryStatement struct {
+	Position Position
+	Type     string
+
+	Body    *Block
+	Error   *Variable
+	Catch   *Block
+	Finally *Block
+}
+
+func (l *TryStatement) GetPosition() Position {
+	return l.Position
+}
+
+func (t *TryStatement) String() string {
+	sb := new(strings.Builder)
+	sb.WriteString(fmt.Sprintf("try {%s}", block(t.Body)))
+	if t.Catch != nil {
+		if t.Error != nil {
+			sb.WriteString(fmt.Sprintf(" catch %s {%s}", t.Error.String(), block(t.Catch)))
+		} else {
+			sb.WriteString(fmt.Sprintf(" catch {%s}", block(t.Catch)))
+		}
+	}
+	if t.Finally != nil {
+		sb.WriteString(fmt.Sprintf(" finally {%s}", block(t.Finally)))
+	}
+	return sb.String()
+}
+
 // FORStatement like for
 type FORStatement struct {
 	Position Position
@@ -430,7 +609,7 @@ func (f *FORStatement) String() string {
 	return fmt.Sprintf("for %s, %s in %s {\n%s\n}",
 		f.CurrentIndex.String(),
 		f.CurrentItem.String(),
-		f.Iterable.Name.String(),
+		f.Iterable.String(),
 		intent(f.Block.String()))
 }
 
@@ -439,7 +618,7 @@ type IterableExpression struct {
 	Position Position
 	Type     string
 
-	Name  Variable
+	Value Statement
 	Index int
 	Items []Statement
 }
@@ -449,7 +628,10 @@ func (l *IterableExpression) GetPosition() Position {
 }
 
 func (i *IterableExpression) String() string {
-	return ""
+	if i.Value == nil {
+		return ""
+	}
+	return i.Value.String()
 }
 
 // Next part of IterableExpression
@@ -524,8 +706,13 @@ func (l *Field) GetPosition() Position {
 }
 
 func (f *Field) String() string {
-	if v, ok := f.Value.(*Variable); ok && strings.Contains(v.Name, "-") {
-		return fmt.Sprintf("%s[%s]", f.Variable.String(), f.Value.String())
+	switch v := f.Value.(type) {
+	case *Variable:
+		return fmt.Sprintf("%s[%s]", f.Variable.String(), v.Name)
+	case *StringExpression:
+		if !isName(v.Value) {
+			return fmt.Sprintf("%s['%s']", f.Variable.String(), v.Value)
+		}
 	}
 	return fmt.Sprintf("%s.%s", f.Variable.String(), f.Value.String())
 }
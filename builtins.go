@@ -1,7 +1,6 @@
 package funny
 
 import (
-	"context"
 	"crypto/md5"
 	"database/sql"
 	_ "embed"
@@ -14,6 +13,8 @@ import (
 	"os/exec"
 	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,41 +32,67 @@ var BuiltinsDotFunny string
 type BuiltinFunction func(fn *Funny, args []Value) Value
 
 var (
-	// FUNCTIONS all builtin functions
+	// FUNCTIONS all builtin functions, interpreters only read it and copy it before
+	// registering their own functions
 	FUNCTIONS = map[string]BuiltinFunction{
-		"echo":          Echo,
-		"echoln":        Echoln,
-		"now":           Now,
-		"b64en":         Base64Encode,
-		"b64de":         Base64Decode,
-		"assert":        Assert,
-		"len":           Len,
-		"md5":           Md5,
-		"max":           Max,
-		"min":           Min,
-		"typeof":        Typeof,
-		"uuid":          UUID,
-		"httpreq":       HttpRequest,
-		"env":           Env,
-		"strjoin":       StrJoin,
-		"strsplit":      StrSplit,
-		"str":           Str,
-		"int":           Int,
-		"jwten":         JwtEncode,
-		"jwtde":         JwtDecode,
-		"sqlquery":      SqlQuery,
-		"sqlexec":       SqlExec,
-		"sqlexecfile":   SqlExecFile,
-		"format":        FormatData,
-		"dumpruntimes":  DumpRuntimes,
-		"readtext":      ReadText,
-		"writetext":     WriteText,
-		"readjson":      ReadJson,
-		"writejson":     WriteJson,
-		"regexMatch":    RegexMatch,
-		"regexMapMatch": RegexMapMatch,
-		"regexMapValue": RegexMapValue,
-		"sh":            Sh,
+		"echo":             Echo,
+		"echoln":           Echoln,
+		"readline":         ReadLine,
+		"input":            Input,
+		"now":              Now,
+		"b64en":            Base64Encode,
+		"b64de":            Base64Decode,
+		"assert":           Assert,
+		"assertEq":         AssertEq,
+		"assertNe":         AssertNe,
+		"assertContains":   AssertContains,
+		"assertMatch":      AssertMatch,
+		"assertType":       AssertType,
+		"assertThrows":     AssertThrows,
+		"assertJsonSubset": AssertJsonSubset,
+		"throw":            Throw,
+		"len":              Len,
+		"md5":              Md5,
+		"max":              Max,
+		"min":              Min,
+		"typeof":           Typeof,
+		"uuid":             UUID,
+		"httpreq":          HttpRequest,
+		"http.request":     HttpSend,
+		"env":              Env,
+		"strjoin":          StrJoin,
+		"strsplit":         StrSplit,
+		"str":              Str,
+		"int":              Int,
+		"float":            Float,
+		"jwten":            JwtEncode,
+		"jwtde":            JwtDecode,
+		"sqlquery":         SqlQuery,
+		"sqlexec":          SqlExec,
+		"sqlexecfile":      SqlExecFile,
+		"format":           FormatData,
+		"dumpruntimes":     DumpRuntimes,
+		"readtext":         ReadText,
+		"writetext":        WriteText,
+		"readjson":         ReadJson,
+		"writejson":        WriteJson,
+		"regexMatch":       RegexMatch,
+		"regexMapMatch":    RegexMapMatch,
+		"regexMapValue":    RegexMapValue,
+		"sh":               Sh,
+		"map":              Map,
+		"filter":           Filter,
+		"reduce":           Reduce,
+		"sort":             Sort,
+		"find":             Find,
+		"any":              Any,
+		"all":              All,
+		"groupby":          GroupBy,
+		"uniq":             Uniq,
+		"append":           Append,
+		"pop":              Pop,
+		"insert":           Insert,
+		"remove":           Remove,
 	}
 )
 
@@ -83,46 +110,60 @@ func ackGt(fn *Funny, args []Value, count int) {
 	}
 }
 
+// ackBetween check function arguments count valid
+func ackBetween(fn *Funny, args []Value, min, max int) {
+	if len(args) < min || len(args) > max {
+		panic(P(fmt.Sprintf("%d to %d arguments required but got %d", min, max, len(args)), fn.Current))
+	}
+}
+
 // Echo builtin function echos one or every item in a array
 func Echo(fn *Funny, args []Value) Value {
 	for _, item := range args {
-		switch v := item.(type) {
-		case map[string]Value:
-		case map[string]interface{}:
-			bts, err := json.Marshal(&v)
-			if err != nil {
-				panic(P(err.Error(), fn.Current))
-			}
-			fmt.Print(string(bts))
-		default:
-			fmt.Print(item)
-		}
+		echo(fn, item)
 	}
 	return nil
 }
 
 // Echoln builtin function echos one or every item in a array
 func Echoln(fn *Funny, args []Value) Value {
-	for index, item := range args {
-		switch v := item.(type) {
-		case map[string]Value:
-		case map[string]interface{}:
-			bts, err := json.Marshal(&v)
-			if err != nil {
-				panic(P(err.Error(), fn.Current))
-			}
-			fmt.Print(string(bts))
-		default:
-			fmt.Print(item)
-		}
+	for _, item := range args {
+		echo(fn, item)
+	}
+	fmt.Fprintln(fn.Stdout)
+	return nil
+}
 
-		if index == len(args)-1 {
-			fmt.Print("\n", fn.Current)
+// echo write item to Stdout, dicts are written as json
+func echo(fn *Funny, item Value) {
+	switch v := item.(type) {
+	case map[string]Value, map[string]interface{}:
+		bts, err := json.Marshal(&v)
+		if err != nil {
+			panic(P(err.Error(), fn.Current))
 		}
+		fmt.Fprint(fn.Stdout, string(bts))
+	default:
+		fmt.Fprint(fn.Stdout, item)
+	}
+}
+
+// ReadLine readline() read one line from stdin, nil at the end of input
+func ReadLine(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 0)
+	if line, ok := fn.ReadLine(); ok {
+		return Value(line)
 	}
 	return nil
 }
 
+// Input input(prompt) write prompt to stdout and read one line from stdin
+func Input(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 1)
+	fmt.Fprint(fn.Stdout, args[0])
+	return ReadLine(fn, nil)
+}
+
 // Now builtin function return now time
 func Now(fn *Funny, args []Value) Value {
 	return Value(time.Now())
@@ -162,18 +203,44 @@ func Base64Decode(fn *Funny, args []Value) Value {
 	return Value(results)
 }
 
-// Assert return the value that has been given
+// Assert assert(condition, message) raise error with the optional message if condition is false
 func Assert(fn *Funny, args []Value) Value {
-	ackEq(fn, args, 1)
+	ackGt(fn, args, 0)
 	if val, ok := args[0].(bool); ok {
 		if val {
 			return Value(args[0])
 		}
-		panic(P("assert false", fn.Current))
+		if len(args) > 1 {
+			panic(P(fmt.Sprintf("assertion failed: %v", args[1]), fn.Current))
+		}
+		panic(P("assertion failed", fn.Current))
 	}
 	panic(P("assert type error, only support [bool]", fn.Current))
 }
 
+// Throw raise an error with the given value that can be caught by try catch
+func Throw(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 1)
+	msg := fmt.Sprint(args[0])
+	switch v := args[0].(type) {
+	case string:
+		msg = v
+	case map[string]Value:
+		if m, ok := v["message"]; ok {
+			msg = fmt.Sprint(m)
+		}
+	case map[string]interface{}:
+		if m, ok := v["message"]; ok {
+			msg = fmt.Sprint(m)
+		}
+	}
+	panic(&FunnyRuntimeError{
+		Postion: fn.Current,
+		Msg:     msg,
+		Value:   args[0],
+	})
+}
+
 // Len return then length of the given list
 func Len(fn *Funny, args []Value) Value {
 	ackEq(fn, args, 1)
@@ -202,70 +269,36 @@ func Md5(fn *Funny, args []Value) Value {
 	panic(P("md5 type error, only support [string]", fn.Current))
 }
 
-// Max return then length of the given list
+// Max return the max one of the given numbers
 func Max(fn *Funny, args []Value) Value {
 	ackGt(fn, args, 1)
-	switch v := args[0].(type) {
-	case int:
-		flag := v
-		for _, item := range args[1:] {
-			if val, ok := item.(int); ok {
-				if val > flag {
-					flag = val
-				}
-			}
+	flag := args[0]
+	for _, item := range args[1:] {
+		c, ok := fn.Compare(item, flag)
+		if !ok {
+			panic(P(fmt.Sprintf("max type error, only support [int, float] given [%s]", Typing(item)), fn.Current))
 		}
-		return Value(flag)
-	case *List:
-		flag := fn.EvalExpression(v.Values[0])
-		if flagA, ok := flag.(int); ok {
-			for _, item := range v.Values {
-				val := fn.EvalExpression(item)
-				if val, ok := val.(int); ok {
-					if val > flagA {
-						flagA = val
-					}
-				}
-			}
-			return Value(flagA)
+		if c > 0 {
+			flag = item
 		}
-	default:
-		break
 	}
-	panic(P("max type error, only support [int]", fn.Current))
+	return Value(flag)
 }
 
-// Min return then length of the given list
+// Min return the min one of the given numbers
 func Min(fn *Funny, args []Value) Value {
 	ackGt(fn, args, 1)
-	switch v := args[0].(type) {
-	case int:
-		flag := v
-		for _, item := range args[1:] {
-			if val, ok := item.(int); ok {
-				if val < flag {
-					flag = val
-				}
-			}
+	flag := args[0]
+	for _, item := range args[1:] {
+		c, ok := fn.Compare(item, flag)
+		if !ok {
+			panic(P(fmt.Sprintf("min type error, only support [int, float] given [%s]", Typing(item)), fn.Current))
 		}
-		return Value(flag)
-	case *List:
-		flag := fn.EvalExpression(v.Values[0])
-		if flagA, ok := flag.(int); ok {
-			for _, item := range v.Values {
-				val := fn.EvalExpression(item)
-				if val, ok := val.(int); ok {
-					if val < flagA {
-						flagA = val
-					}
-				}
-			}
-			return Value(flagA)
+		if c < 0 {
+			flag = item
 		}
-	default:
-		break
 	}
-	panic(P("min type error, only support [int]", fn.Current))
+	return Value(flag)
 }
 
 // Typeof builtin function echos one or every item in a array
@@ -281,7 +314,8 @@ func UUID(fn *Funny, args []Value) Value {
 	return Value(u1)
 }
 
-// HttpRequest builtin function for http request
+// HttpRequest httpreq(method, url, data, headers, debug) request url and return the response
+// json, data is the query of GET and the json body of the others, see http.request for more
 func HttpRequest(fn *Funny, args []Value) Value {
 	ackEq(fn, args, 5)
 	method := ""
@@ -312,31 +346,32 @@ func HttpRequest(fn *Funny, args []Value) Value {
 			debug = fn.Debug()
 		}
 	}
+	fn.CheckURL(url)
 	switch method {
 	case "GET":
 		jsonResult := make(map[string]interface{})
-		err := gout.GET(url).Debug(debug).SetQuery(data).SetHeader(headers).BindJSON(&jsonResult).Do()
+		err := gout.New(fn.httpClient()).GET(url).WithContext(fn.Context()).Debug(debug).SetQuery(data).SetHeader(headers).BindJSON(&jsonResult).Do()
 		if err != nil {
 			panic(xerrors.Errorf("response not json format %w", err))
 		}
 		return Value(jsonResult)
 	case "POST":
 		jsonResult := make(map[string]interface{})
-		err := gout.POST(url).Debug(debug).SetJSON(data).SetHeader(headers).BindJSON(&jsonResult).Do()
+		err := gout.New(fn.httpClient()).POST(url).WithContext(fn.Context()).Debug(debug).SetJSON(data).SetHeader(headers).BindJSON(&jsonResult).Do()
 		if err != nil {
 			panic(xerrors.Errorf("response not json format: %w", err))
 		}
 		return Value(jsonResult)
 	case "PUT":
 		jsonResult := make(map[string]interface{})
-		err := gout.PUT(url).Debug(debug).SetHeader(headers).BindJSON(&jsonResult).Do()
+		err := gout.New(fn.httpClient()).PUT(url).WithContext(fn.Context()).Debug(debug).SetJSON(data).SetHeader(headers).BindJSON(&jsonResult).Do()
 		if err != nil {
 			panic(xerrors.Errorf("response not json format: %w", err))
 		}
 		return Value(jsonResult)
 	case "DELETE":
 		jsonResult := make(map[string]interface{})
-		err := gout.DELETE(url).Debug(debug).SetHeader(headers).BindJSON(&jsonResult).Do()
+		err := gout.New(fn.httpClient()).DELETE(url).WithContext(fn.Context()).Debug(debug).SetJSON(data).SetHeader(headers).BindJSON(&jsonResult).Do()
 		if err != nil {
 			panic(xerrors.Errorf("response not json format: %w", err))
 		}
@@ -349,6 +384,7 @@ func HttpRequest(fn *Funny, args []Value) Value {
 func Env(fn *Funny, args []Value) Value {
 	ackGt(fn, args, 0)
 	if key, ok := args[0].(string); ok {
+		fn.CheckEnv(key)
 		val := os.Getenv(key)
 		if val == "" && len(args) > 1 {
 			return Value(args[1])
@@ -375,39 +411,80 @@ func StrJoin(fn *Funny, args []Value) Value {
 	panic(P("strjoin type error, join data only support [array]", fn.Current))
 }
 
-// StrSplit equal strings.Split
+// StrSplit strsplit(s, sep) split s into the list of the strings between sep like strings.Split
 func StrSplit(fn *Funny, args []Value) Value {
-	ackEq(fn, args, 1)
-	if key, ok := args[0].(string); ok {
-		val := os.Getenv(key)
-		if val == "" && len(args) > 1 {
-			return Value(args[1])
-		}
-		return Value(val)
+	ackEq(fn, args, 2)
+	s, ok := args[0].(string)
+	if !ok {
+		panic(P(fmt.Sprintf("strsplit type error, strsplit value only support [string] given [%s]", Typing(args[0])), fn.Current))
+	}
+	sep, ok := args[1].(string)
+	if !ok {
+		panic(P(fmt.Sprintf("strsplit type error, separator only support [string] given [%s]", Typing(args[1])), fn.Current))
 	}
-	panic(P("strsplit type error, strsplit value only support [string]", fn.Current))
+	parts := strings.Split(s, sep)
+	list := make([]interface{}, len(parts))
+	for index, part := range parts {
+		list[index] = part
+	}
+	return Value(list)
 }
 
-// Str like string(1)
+// Str like str(1)
 func Str(fn *Funny, args []Value) Value {
 	ackEq(fn, args, 1)
-	return fmt.Sprint(args[0])
-	// panic(P("str type error, str data only support [string]", fn.Current))
+	switch v := args[0].(type) {
+	case float64:
+		return Value(strconv.FormatFloat(v, 'f', -1, 64))
+	case nil:
+		return Value("nil")
+	}
+	return Value(fmt.Sprint(args[0]))
 }
 
-// Int like int('1')
+// Int like int('1'), int(1.5)
 func Int(fn *Funny, args []Value) Value {
 	ackEq(fn, args, 1)
-	if v, ok := args[0].(time.Time); ok {
+	switch v := args[0].(type) {
+	case time.Time:
 		return Value(int(v.Unix()))
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return Value(i)
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return Value(int(f))
+		}
+		panic(P(fmt.Sprintf("int type error, [%s] is not int format", v), fn.Current))
+	default:
+		if n, ok := toNumber(v); ok {
+			if i, ok := n.(int); ok {
+				return Value(i)
+			}
+			return Value(int(n.(float64)))
+		}
 	}
-	dataStr := fmt.Sprint(args[0])
-	for _, ch := range dataStr {
-		if !isNameStart(ch) {
-			panic(P("int type error, int only support [int format]", fn.Current))
+	panic(P(fmt.Sprintf("int type error, only support [int, float, string, time] given [%s]", Typing(args[0])), fn.Current))
+}
+
+// Float like float('1.5'), float(1)
+func Float(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 1)
+	switch v := args[0].(type) {
+	case time.Time:
+		return Value(float64(v.UnixNano()) / float64(time.Second))
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			panic(P(fmt.Sprintf("float type error, [%s] is not float format", v), fn.Current))
+		}
+		return Value(f)
+	default:
+		if n, ok := toNumber(v); ok {
+			return Value(toFloat(n))
 		}
 	}
-	panic(P("int type error, int only support [int format]", fn.Current))
+	panic(P(fmt.Sprintf("float type error, only support [int, float, string, time] given [%s]", Typing(args[0])), fn.Current))
 }
 
 // JwtEncode jwten(method, secret, claims) string
@@ -475,6 +552,7 @@ func SqlQuery(fn *Funny, args []Value) Value {
 	ackGt(fn, args, 1)
 	switch v := args[0].(type) {
 	case map[string]Value:
+		fn.checkDatabase(v)
 		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 			v["user"],
 			v["password"],
@@ -491,7 +569,7 @@ func SqlQuery(fn *Funny, args []Value) Value {
 		for _, arg := range args[2:] {
 			sqlArgs = append(sqlArgs, arg)
 		}
-		rows, err := db.Query(fmt.Sprint(args[1]), sqlArgs...)
+		rows, err := db.QueryContext(fn.Context(), fmt.Sprint(args[1]), sqlArgs...)
 		if err != nil {
 			panic(P(err.Error(), fn.Current))
 		}
@@ -530,6 +608,7 @@ func SqlExec(fn *Funny, args []Value) Value {
 	ackGt(fn, args, 1)
 	switch v := args[0].(type) {
 	case map[string]Value:
+		fn.checkDatabase(v)
 		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 			v["user"],
 			v["password"],
@@ -546,7 +625,7 @@ func SqlExec(fn *Funny, args []Value) Value {
 		for _, arg := range args[2:] {
 			sqlArgs = append(sqlArgs, arg)
 		}
-		result, err := db.Exec(fmt.Sprint(args[1]), sqlArgs...)
+		result, err := db.ExecContext(fn.Context(), fmt.Sprint(args[1]), sqlArgs...)
 		if err != nil {
 			panic(P(err.Error(), fn.Current))
 		}
@@ -583,7 +662,7 @@ func DumpRuntimes(fn *Funny, args []Value) Value {
 	if err != nil {
 		panic(P(err.Error(), fn.Current))
 	}
-	fmt.Println(string(bts))
+	fmt.Fprintln(fn.Stdout, string(bts))
 	return Value(string(bts))
 }
 
@@ -595,6 +674,7 @@ func ReadText(fn *Funny, args []Value) Value {
 			d := path.Dir(fn.Current.File)
 			filename = path.Join(d, filename)
 		}
+		fn.CheckRead(filename)
 		bts, err := os.ReadFile(filename)
 		if err != nil {
 			panic(xerrors.Errorf("read file error: %w", err))
@@ -613,6 +693,7 @@ func WriteText(fn *Funny, args []Value) Value {
 				d := path.Dir(fn.Current.File)
 				filename = path.Join(d, filename)
 			}
+			fn.CheckWrite(filename)
 			err := os.WriteFile(filename, []byte(text), fs.ModeAppend)
 			if err != nil {
 				panic(xerrors.Errorf("write error: %w", err))
@@ -630,6 +711,7 @@ func ReadJson(fn *Funny, args []Value) Value {
 			d := path.Dir(fn.Current.File)
 			filename = path.Join(d, filename)
 		}
+		fn.CheckRead(filename)
 		bts, err := os.ReadFile(filename)
 		if err != nil {
 			panic(xerrors.Errorf("read file error: %w", err))
@@ -656,6 +738,7 @@ func WriteJson(fn *Funny, args []Value) Value {
 			d := path.Dir(fn.Current.File)
 			filename = path.Join(d, filename)
 		}
+		fn.CheckWrite(filename)
 		err = os.WriteFile(filename, []byte(bts), 0644)
 		if err != nil {
 			panic(xerrors.Errorf("write error: %w", err))
@@ -673,6 +756,7 @@ func SqlExecFile(fn *Funny, args []Value) Value {
 			d := path.Dir(fn.Current.File)
 			filename = path.Join(d, filename)
 		}
+		fn.CheckRead(filename)
 		bts, err := os.ReadFile(filename)
 		if err != nil {
 			panic(xerrors.Errorf("read file error: %w", err))
@@ -681,6 +765,7 @@ func SqlExecFile(fn *Funny, args []Value) Value {
 		if !connOk {
 			panic(xerrors.Errorf("connection must dict"))
 		}
+		fn.checkDatabase(v)
 		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local&multiStatements=true",
 			v["user"],
 			v["password"],
@@ -693,7 +778,7 @@ func SqlExecFile(fn *Funny, args []Value) Value {
 			panic(P(err.Error(), fn.Current))
 		}
 		defer db.Close()
-		tx, err := db.BeginTx(context.Background(), &sql.TxOptions{})
+		tx, err := db.BeginTx(fn.Context(), &sql.TxOptions{})
 		if err != nil {
 			panic(P(err.Error(), fn.Current))
 		}
@@ -705,7 +790,7 @@ func SqlExecFile(fn *Funny, args []Value) Value {
 		// 		panic(P(err.Error(), fn.Current))
 		// 	}
 		// }
-		_, err = tx.Exec(string(bts))
+		_, err = tx.ExecContext(fn.Context(), string(bts))
 		if err != nil {
 			err = tx.Rollback()
 			if err != nil {
@@ -812,8 +897,9 @@ func RegexMapValue(fn *Funny, args []Value) Value {
 func Sh(fn *Funny, args []Value) Value {
 	ackEq(fn, args, 1)
 	if command, ok := args[0].(string); ok {
-		cmd := exec.Command(command)
-		cmd.Stderr = os.Stderr
+		fn.CheckRun(command)
+		cmd := exec.CommandContext(fn.Context(), command)
+		cmd.Stderr = fn.Stderr
 		bts, err := cmd.Output()
 		if err != nil {
 			panic(P(fmt.Sprintf("sh command error %s", err.Error()), fn.Current))
@@ -822,3 +908,322 @@ func Sh(fn *Funny, args []Value) Value {
 	}
 	panic(P(fmt.Sprintf("argument command except type string but got %s", Typing(args[0])), fn.Current))
 }
+
+// listArg get the argument at index as a list
+func listArg(fn *Funny, name string, args []Value, index int) []interface{} {
+	if ls, ok := args[index].([]interface{}); ok {
+		return ls
+	}
+	panic(P(fmt.Sprintf("%s type error, only support [list] given [%s]", name, Typing(args[index])), fn.Current))
+}
+
+// callback call function f with at most as many args as it accepts,
+// so builtins like str can be used as one argument callbacks
+func callback(fn *Funny, f Value, args ...Value) Value {
+	count := 1
+	switch f := f.(type) {
+	case Callable:
+		count = f.Arity()
+	case *Function:
+		count = len(f.Parameters)
+	}
+	if count < len(args) {
+		args = args[:count]
+	}
+	return fn.CallFunction(f, args)
+}
+
+// callbackBool call function f and the result must be a bool value
+func callbackBool(fn *Funny, name string, f Value, args ...Value) bool {
+	r := callback(fn, f, args...)
+	if b, ok := r.(bool); ok {
+		return b
+	}
+	panic(P(fmt.Sprintf("%s function must return [bool] but got [%s]", name, Typing(r)), fn.Current))
+}
+
+// sortedKeys keys of dict in order
+func sortedKeys(m map[string]Value) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dictArg get a dict argument as map[string]Value, json objects are converted
+func dictArg(v Value) (map[string]Value, bool) {
+	switch v := v.(type) {
+	case map[string]Value:
+		return v, true
+	case map[string]interface{}:
+		m := make(map[string]Value, len(v))
+		for key, val := range v {
+			m[key] = val
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// Map call f(item, index) on each item of list or f(value, key) on each value of dict
+func Map(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 2)
+	if m, ok := dictArg(args[0]); ok {
+		result := make(map[string]Value, len(m))
+		for _, key := range sortedKeys(m) {
+			result[key] = callback(fn, args[1], m[key], key)
+		}
+		return Value(result)
+	}
+	ls := listArg(fn, "map", args, 0)
+	result := make([]interface{}, 0, len(ls))
+	for index, item := range ls {
+		result = append(result, callback(fn, args[1], item, index))
+	}
+	return Value(result)
+}
+
+// Filter keep items of list or values of dict that f returns true
+func Filter(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 2)
+	if m, ok := dictArg(args[0]); ok {
+		result := make(map[string]Value)
+		for _, key := range sortedKeys(m) {
+			if callbackBool(fn, "filter", args[1], m[key], key) {
+				result[key] = m[key]
+			}
+		}
+		return Value(result)
+	}
+	ls := listArg(fn, "filter", args, 0)
+	result := make([]interface{}, 0)
+	for index, item := range ls {
+		if callbackBool(fn, "filter", args[1], item, index) {
+			result = append(result, item)
+		}
+	}
+	return Value(result)
+}
+
+// Reduce fold list with f(acc, item, index), the first item is the initial value if not given
+func Reduce(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 1)
+	ls := listArg(fn, "reduce", args, 0)
+	var acc Value
+	if len(args) > 2 {
+		acc = args[2]
+	} else {
+		if len(ls) == 0 {
+			panic(P("reduce of empty list with no initial value", fn.Current))
+		}
+		acc, ls = ls[0], ls[1:]
+	}
+	for index, item := range ls {
+		acc = callback(fn, args[1], acc, item, index)
+	}
+	return acc
+}
+
+// Sort return a new sorted list, cmp(a, b) returns true or a negative number when a is less than b
+func Sort(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 0)
+	ls := listArg(fn, "sort", args, 0)
+	result := make([]interface{}, len(ls))
+	copy(result, ls)
+	less := func(a, b Value) bool {
+		return sortCompare(fn, a, b) < 0
+	}
+	if len(args) > 1 {
+		less = func(a, b Value) bool {
+			r := callback(fn, args[1], a, b)
+			if b, ok := r.(bool); ok {
+				return b
+			}
+			if c, ok := fn.Compare(r, 0); ok {
+				return c < 0
+			}
+			panic(P(fmt.Sprintf("sort function must return [bool, int, float] but got [%s]", Typing(r)), fn.Current))
+		}
+	}
+	sort.SliceStable(result, func(x, y int) bool {
+		return less(result[x], result[y])
+	})
+	return Value(result)
+}
+
+// sortCompare compare numbers or strings
+func sortCompare(fn *Funny, a, b Value) int {
+	if c, ok := fn.Compare(a, b); ok {
+		return c
+	}
+	if l, ok := a.(string); ok {
+		if r, ok := b.(string); ok {
+			return strings.Compare(l, r)
+		}
+	}
+	panic(P(fmt.Sprintf("sort can not compare [%s] with [%s]", Typing(a), Typing(b)), fn.Current))
+}
+
+// Find return the first item of list that f returns true, nil if not found
+func Find(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 2)
+	for index, item := range listArg(fn, "find", args, 0) {
+		if callbackBool(fn, "find", args[1], item, index) {
+			return Value(item)
+		}
+	}
+	return nil
+}
+
+// Any return true if f returns true for any item, items must be bool if f not given
+func Any(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 0)
+	for index, item := range listArg(fn, "any", args, 0) {
+		if predicate(fn, "any", args, item, index) {
+			return Value(true)
+		}
+	}
+	return Value(false)
+}
+
+// All return true if f returns true for every item, items must be bool if f not given
+func All(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 0)
+	for index, item := range listArg(fn, "all", args, 0) {
+		if !predicate(fn, "all", args, item, index) {
+			return Value(false)
+		}
+	}
+	return Value(true)
+}
+
+// predicate call the optional function args[1] or use item as bool
+func predicate(fn *Funny, name string, args []Value, item Value, index int) bool {
+	if len(args) > 1 {
+		return callbackBool(fn, name, args[1], item, index)
+	}
+	if b, ok := item.(bool); ok {
+		return b
+	}
+	panic(P(fmt.Sprintf("%s item must be [bool] but got [%s]", name, Typing(item)), fn.Current))
+}
+
+// GroupBy group items of list into a dict by the key f returns
+func GroupBy(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 2)
+	result := make(map[string]Value)
+	for index, item := range listArg(fn, "groupby", args, 0) {
+		key := Str(fn, []Value{callback(fn, args[1], item, index)}).(string)
+		group, _ := result[key].([]interface{})
+		result[key] = append(group, item)
+	}
+	return Value(result)
+}
+
+// Uniq remove duplicated items of list, items are compared by the key f returns if given
+func Uniq(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 0)
+	result := make([]interface{}, 0)
+	seen := make(map[string]bool)
+	for index, item := range listArg(fn, "uniq", args, 0) {
+		key := item
+		if len(args) > 1 {
+			key = callback(fn, args[1], item, index)
+		}
+		k := uniqKey(key)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		result = append(result, item)
+	}
+	return Value(result)
+}
+
+// uniqKey identity of value, int and float with same number are equal
+func uniqKey(v Value) string {
+	if n, ok := toNumber(v); ok {
+		return fmt.Sprintf("number:%v", toFloat(n))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%T:%v", v, v)
+	}
+	return fmt.Sprintf("%T:%s", v, data)
+}
+
+// itemIndex check index of list item, negative index counts from the end,
+// length itself is allowed when inserting
+func itemIndex(fn *Funny, name string, index Value, length int, inserting bool) int {
+	n, ok := index.(int)
+	if !ok {
+		panic(P(fmt.Sprintf("%s index must be [int] given [%s]", name, Typing(index)), fn.Current))
+	}
+	if n < 0 {
+		n += length
+	}
+	limit := length
+	if inserting {
+		limit++
+	}
+	if n < 0 || n >= limit {
+		panic(P(fmt.Sprintf("%s index %d out of range with length %d", name, index, length), fn.Current))
+	}
+	return n
+}
+
+// Append return a new list with items appended, the list is not changed, use it like ls = append(ls, 1, 2)
+func Append(fn *Funny, args []Value) Value {
+	ackGt(fn, args, 0)
+	ls := listArg(fn, "append", args, 0)
+	result := make([]interface{}, 0, len(ls)+len(args)-1)
+	result = append(result, ls...)
+	for _, item := range args[1:] {
+		result = append(result, item)
+	}
+	return Value(result)
+}
+
+// Pop return the last item of list or the item at index, and a new list without it. The list
+// is not changed, use it like r = pop(ls), item = r[0], ls = r[1]
+func Pop(fn *Funny, args []Value) Value {
+	ackBetween(fn, args, 1, 2)
+	ls := listArg(fn, "pop", args, 0)
+	if len(ls) == 0 {
+		panic(P("pop from empty list", fn.Current))
+	}
+	index := Value(-1)
+	if len(args) > 1 {
+		index = args[1]
+	}
+	n := itemIndex(fn, "pop", index, len(ls), false)
+	rest := make([]interface{}, 0, len(ls)-1)
+	rest = append(rest, ls[:n]...)
+	rest = append(rest, ls[n+1:]...)
+	return Value([]interface{}{ls[n], rest})
+}
+
+// Insert return a new list with item inserted before index, the list is not changed
+func Insert(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 3)
+	ls := listArg(fn, "insert", args, 0)
+	index := itemIndex(fn, "insert", args[1], len(ls), true)
+	result := make([]interface{}, 0, len(ls)+1)
+	result = append(result, ls[:index]...)
+	result = append(result, args[2])
+	result = append(result, ls[index:]...)
+	return Value(result)
+}
+
+// Remove return a new list without the item at index, the list is not changed
+func Remove(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 2)
+	ls := listArg(fn, "remove", args, 0)
+	index := itemIndex(fn, "remove", args[1], len(ls), false)
+	result := make([]interface{}, 0, len(ls)-1)
+	result = append(result, ls[:index]...)
+	result = append(result, ls[index+1:]...)
+	return Value(result)
+}
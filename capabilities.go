@@ -0,0 +1,205 @@
+package funny
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPermissionDenied the error caused by builtins doing what the capabilities do not allow
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Capabilities what the dangerous builtins are allowed to do, like reading files, sending
+// requests and running commands. The zero value denies everything, and an interpreter
+// whose Capabilities is nil is not restricted at all
+type Capabilities struct {
+	// Root the directory no file outside of it can be read or written if it is not empty
+	Root string
+	// Read the files and directories can be read, * for all
+	Read []string
+	// Write the files and directories can be written, * for all
+	Write []string
+	// Net the hosts can be connected by http requests and sql, like example.com, example.com:8080
+	// or *.example.com, * for all
+	Net []string
+	// Run whether sh can run commands
+	Run bool
+	// Env the environment variables can be read, * for all
+	Env []string
+}
+
+// WithCapabilities restrict the builtins by c
+func WithCapabilities(c *Capabilities) Option {
+	return func(fn *Funny) {
+		fn.Capabilities = c
+	}
+}
+
+// CheckRead raise error if file filename can not be read
+func (i *Funny) CheckRead(filename string) {
+	if c := i.Capabilities; c != nil && !c.allowPath(c.Read, filename) {
+		panic(permissionError(fmt.Sprintf("read file [%s]", filename), i.Current))
+	}
+}
+
+// CheckWrite raise error if file filename can not be written
+func (i *Funny) CheckWrite(filename string) {
+	if c := i.Capabilities; c != nil && !c.allowPath(c.Write, filename) {
+		panic(permissionError(fmt.Sprintf("write file [%s]", filename), i.Current))
+	}
+}
+
+// CheckNet raise error if host can not be connected, host may contain the port
+func (i *Funny) CheckNet(host string) {
+	if c := i.Capabilities; c != nil && !c.allowHost(host) {
+		panic(permissionError(fmt.Sprintf("connect to host [%s]", host), i.Current))
+	}
+}
+
+// CheckURL raise error if the host of rawURL can not be connected
+func (i *Funny) CheckURL(rawURL string) {
+	if err := i.urlError(rawURL); err != nil {
+		panic(err)
+	}
+}
+
+// urlError the error CheckURL raises for rawURL, nil if it can be requested
+func (i *Funny) urlError(rawURL string) *FunnyRuntimeError {
+	c := i.Capabilities
+	if c == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return permissionError(fmt.Sprintf("request url [%s]", rawURL), i.Current)
+	}
+	if !c.allowHost(u.Host) {
+		return permissionError(fmt.Sprintf("connect to host [%s]", u.Host), i.Current)
+	}
+	return nil
+}
+
+// CheckRun raise error if command can not be run
+func (i *Funny) CheckRun(command string) {
+	if c := i.Capabilities; c != nil && !c.Run {
+		panic(permissionError(fmt.Sprintf("run command [%s]", command), i.Current))
+	}
+}
+
+// CheckEnv raise error if environment variable key can not be read
+func (i *Funny) CheckEnv(key string) {
+	if c := i.Capabilities; c != nil && !contains(c.Env, key) {
+		panic(permissionError(fmt.Sprintf("read env [%s]", key), i.Current))
+	}
+}
+
+// checkDatabase raise error if the database of connection conn used by the sql builtins
+// can not be connected
+func (i *Funny) checkDatabase(conn map[string]Value) {
+	host := fmt.Sprint(conn["host"])
+	if port, ok := conn["port"]; ok && port != nil {
+		host = net.JoinHostPort(host, fmt.Sprint(port))
+	}
+	i.CheckNet(host)
+}
+
+func permissionError(action string, pos Position) *FunnyRuntimeError {
+	return &FunnyRuntimeError{
+		Postion: pos,
+		Msg:     fmt.Sprintf("permission denied: %s is not allowed", action),
+		Err:     ErrPermissionDenied,
+	}
+}
+
+// contains whether allowed has item or *
+func contains(allowed []string, item string) bool {
+	for _, a := range allowed {
+		if a == "*" || a == item {
+			return true
+		}
+	}
+	return false
+}
+
+// allowPath whether filename is in root and one of allowed
+func (c *Capabilities) allowPath(allowed []string, filename string) bool {
+	p, ok := realPath(filename)
+	if !ok {
+		return false
+	}
+	if c.Root != "" {
+		if root, ok := realPath(c.Root); !ok || !within(root, p) {
+			return false
+		}
+	}
+	for _, a := range allowed {
+		if a == "*" {
+			return true
+		}
+		if dir, ok := realPath(a); ok && within(dir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHost whether host matches one of Net, an entry without port allows every port
+func (c *Capabilities) allowHost(host string) bool {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = host, ""
+	}
+	name = strings.ToLower(name)
+	for _, a := range c.Net {
+		if a == "*" {
+			return true
+		}
+		allowName, allowPort, err := net.SplitHostPort(a)
+		if err != nil {
+			allowName, allowPort = a, ""
+		}
+		allowName = strings.ToLower(allowName)
+		if allowPort != "" && allowPort != port {
+			continue
+		}
+		if allowName == name || strings.HasPrefix(allowName, "*.") && strings.HasSuffix(name, allowName[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath the absolute path of p with symbolic links resolved as far as it exists, it is
+// not ok if p is a broken link which may point to anywhere
+func realPath(p string) (string, bool) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, true
+	}
+	if _, err := os.Lstat(abs); err == nil {
+		return "", false
+	}
+	dir, base := filepath.Split(abs)
+	dir = filepath.Clean(dir)
+	if dir == abs || base == "" {
+		return abs, true
+	}
+	real, ok := realPath(dir)
+	return filepath.Join(real, base), ok
+}
+
+// within whether p is dir or in it
+func within(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return false
+	}
+	return rel == "." || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,148 @@
+package funny
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitiesFiles(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	assert.Nil(t, os.Mkdir(allowed, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(allowed, "a.txt"), []byte("hello"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644))
+
+	fn := NewFunny(WithCapabilities(&Capabilities{
+		Read:  []string{allowed},
+		Write: []string{allowed},
+	}))
+	r, err := fn.Run(fmt.Sprintf("return readtext('%s')", filepath.Join(allowed, "a.txt")))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", r)
+
+	_, err = fn.Run(fmt.Sprintf("writejson('%s', {a = 1})", filepath.Join(allowed, "b.json")))
+	assert.Nil(t, err)
+
+	// .. can not escape the allowed directory
+	_, err = fn.Run(fmt.Sprintf("a = 1\nreadtext('%s')", filepath.Join(allowed, "..", "secret.txt")))
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	assert.Contains(t, err.(*FunnyRuntimeError).Msg, "permission denied: read file")
+	assert.Equal(t, 1, err.(*FunnyRuntimeError).Postion.Line)
+
+	// nor a symbolic link in it
+	link := filepath.Join(allowed, "link.txt")
+	if os.Symlink(filepath.Join(dir, "secret.txt"), link) == nil {
+		_, err = fn.Run(fmt.Sprintf("readtext('%s')", link))
+		assert.True(t, errors.Is(err, ErrPermissionDenied))
+	}
+
+	_, err = fn.Run(fmt.Sprintf("writejson('%s', {a = 1})", filepath.Join(dir, "c.json")))
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+	_, statErr := os.Stat(filepath.Join(dir, "c.json"))
+	assert.True(t, os.IsNotExist(statErr))
+
+	// root restricts even the allowed files
+	fn.Capabilities.Root = filepath.Join(dir, "other")
+	_, err = fn.Run(fmt.Sprintf("readtext('%s')", filepath.Join(allowed, "a.txt")))
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+}
+
+func TestCapabilitiesDenied(t *testing.T) {
+	os.Setenv("FUNNY_CAPABILITY", "yes")
+	defer os.Unsetenv("FUNNY_CAPABILITY")
+
+	fn := NewFunny(WithCapabilities(&Capabilities{
+		Env: []string{"FUNNY_CAPABILITY"},
+	}))
+	r, err := fn.Run("return env('FUNNY_CAPABILITY')")
+	assert.Nil(t, err)
+	assert.Equal(t, "yes", r)
+
+	for _, script := range []string{
+		"env('HOME')",
+		"sh('ls')",
+		"httpreq('GET', 'http://example.com/', {}, {}, false)",
+		"db = {\nhost = 'db.example.com'\nport = 3306\n}\nsqlquery(db, 'select 1')",
+	} {
+		_, err := fn.Run(script)
+		assert.True(t, errors.Is(err, ErrPermissionDenied), script)
+	}
+
+	// try can catch the error
+	r, err = fn.Run(`
+try {
+  sh('ls')
+} catch err {
+  return err.message
+}
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "permission denied: run command [ls] is not allowed", r)
+}
+
+func TestCapabilitiesHosts(t *testing.T) {
+	c := &Capabilities{
+		Net: []string{"example.com", "*.funny.dev", "localhost:8080"},
+	}
+	assert.True(t, c.allowHost("example.com"))
+	assert.True(t, c.allowHost("EXAMPLE.com:443"))
+	assert.False(t, c.allowHost("api.example.com"))
+	assert.True(t, c.allowHost("api.funny.dev"))
+	assert.False(t, c.allowHost("funny.dev"))
+	assert.True(t, c.allowHost("localhost:8080"))
+	assert.False(t, c.allowHost("localhost:8081"))
+
+	// unrestricted without capabilities
+	fn := NewFunny()
+	fn.CheckRun("ls")
+	fn.CheckNet("example.org")
+}
+
+func TestCapabilitiesImport(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	assert.Nil(t, os.Mkdir(allowed, 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(allowed, "lib.funny"), []byte("add(a, b) {\n  return a + b\n}\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "secret.funny"), []byte("a = 1\n"), 0644))
+	main := filepath.Join(allowed, "main.funny")
+	assert.Nil(t, os.WriteFile(main, []byte("import('./lib.funny')\nreturn add(1, 2)\n"), 0644))
+
+	fn := NewFunny(WithCapabilities(&Capabilities{
+		Read: []string{allowed},
+	}))
+	r, err := fn.RunFile(main)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r)
+
+	// the imports can not escape the allowed directory either
+	assert.Nil(t, os.WriteFile(main, []byte("import('../secret.funny')\n"), 0644))
+	_, err = fn.RunFile(main)
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+}
+
+func TestCapabilitiesRedirect(t *testing.T) {
+	target := newEchoServer()
+	defer target.Close()
+	server := httptest.NewServer(http.RedirectHandler(target.URL+"/echo", http.StatusFound))
+	defer server.Close()
+
+	fn := NewFunny(WithCapabilities(&Capabilities{
+		Net: []string{server.Listener.Addr().String()},
+	}))
+	fn.Assign("base", server.URL)
+	_, err := fn.Run("http.request({\n  url = base\n})")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+
+	// the redirect is followed if the target can be connected too
+	fn.Capabilities.Net = append(fn.Capabilities.Net, target.Listener.Addr().String())
+	r, err := fn.Run("return http.request({\n  url = base\n}).status")
+	assert.Nil(t, err)
+	assert.Equal(t, 200, r)
+}
@@ -0,0 +1,221 @@
+package funny
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoRecording the error of a request which has no recorded response in strict replay
+var ErrNoRecording = errors.New("no recorded response")
+
+// Cassette a http.RoundTripper which records the responses of requests into JSON files of
+// Dir, or replays them from those files. Requests are matched by method, url and body. When
+// replaying, the requests not recorded are still sent by the network with a warning in Log,
+// unless Strict is set
+type Cassette struct {
+	Dir string
+	// Record whether to send requests and save the responses, or replay the saved ones
+	Record bool
+	// Strict requests not recorded fail with ErrNoRecording when replaying, rather than
+	// being sent
+	Strict bool
+	// Next the transport requests are sent by, http.DefaultTransport if it is nil
+	Next http.RoundTripper
+	// Log where the warnings of the requests sent when replaying go, os.Stderr if it is nil
+	Log io.Writer
+}
+
+// cassetteHiddenHeaders the request headers not saved since they are secrets
+var cassetteHiddenHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Recording one request and its response saved by Cassette
+type Recording struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest the request of a recording, the body is in base64 if it is not text
+type RecordedRequest struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"bodyBase64,omitempty"`
+}
+
+// RecordedResponse the response of a recording, the body is in base64 if it is not text
+type RecordedResponse struct {
+	Status     int                 `json:"status"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       string              `json:"body,omitempty"`
+	BodyBase64 string              `json:"bodyBase64,omitempty"`
+}
+
+// RoundTrip record or replay req
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(c.Dir, CassetteName(req.Method, req.URL.String(), body))
+	if !c.Record {
+		data, err := os.ReadFile(file)
+		if err == nil {
+			var recording Recording
+			if err := json.Unmarshal(data, &recording); err != nil {
+				return nil, fmt.Errorf("read cassette %s: %w", file, err)
+			}
+			return recording.Response.response(req)
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if c.Strict {
+			return nil, fmt.Errorf("%w for %s %s in %s", ErrNoRecording, req.Method, req.URL, c.Dir)
+		}
+		fmt.Fprintf(c.log(), "warning: %s for %s %s in %s, sending it\n", ErrNoRecording, req.Method, req.URL, c.Dir)
+		return c.next().RoundTrip(req)
+	}
+	resp, err := c.next().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	recording := Recording{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Headers: recordedHeaders(req.Header),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: resp.Header,
+		},
+	}
+	recording.Request.Body, recording.Request.BodyBase64 = encodeBody(body)
+	recording.Response.Body, recording.Response.BodyBase64 = encodeBody(respBody)
+	data, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *Cassette) next() http.RoundTripper {
+	if c.Next != nil {
+		return c.Next
+	}
+	return http.DefaultTransport
+}
+
+func (c *Cassette) log() io.Writer {
+	if c.Log != nil {
+		return c.Log
+	}
+	return os.Stderr
+}
+
+var cassetteNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// CassetteName the file name a request is recorded in, which is its method, host and path
+// followed by the hash of method, url and body
+func CassetteName(method, rawURL string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + "\n" + rawURL + "\n"))
+	hash.Write(body)
+	name := rawURL
+	if index := strings.Index(name, "://"); index >= 0 {
+		name = name[index+3:]
+	}
+	if index := strings.IndexAny(name, "?#"); index >= 0 {
+		name = name[:index]
+	}
+	name = strings.Trim(cassetteNameUnsafe.ReplaceAllString(name, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToUpper(method), name, hex.EncodeToString(hash.Sum(nil))[:16])
+}
+
+// readBody read the body of req and replace it so it can be sent
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// recordedHeaders the headers of a request without the secrets
+func recordedHeaders(header http.Header) map[string][]string {
+	headers := header.Clone()
+	for _, key := range cassetteHiddenHeaders {
+		headers.Del(key)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
+// encodeBody body as text, or in base64 if it is not text
+func encodeBody(body []byte) (text, encoded string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return "", base64.StdEncoding.EncodeToString(body)
+}
+
+// response the http response of r for req
+func (r RecordedResponse) response(req *http.Request) (*http.Response, error) {
+	body := []byte(r.Body)
+	if r.BodyBase64 != "" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(r.BodyBase64); err != nil {
+			return nil, err
+		}
+	}
+	header := http.Header(r.Headers)
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
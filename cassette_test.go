@@ -0,0 +1,84 @@
+package funny
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cassetteScript = `
+resp = http.request({
+  method = 'POST'
+  url = base + '/echo'
+  headers = {
+    Authorization = 'Bearer secret'
+  }
+  json = {
+    name = 'funny'
+  }
+})
+missing = http.request({
+  url = base + '/missing'
+})
+`
+
+func TestCassetteRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	server := newEchoServer()
+	base := server.URL
+
+	record := NewFunny(WithHTTPClient(&http.Client{Transport: &Cassette{Dir: dir, Record: true}}))
+	record.Assign("base", base)
+	_, err := record.Run(cassetteScript)
+	assert.Nil(t, err)
+	server.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(files))
+	data, err := os.ReadFile(filepath.Join(dir, CassetteName("POST", base+"/echo", []byte(`{"name":"funny"}`))))
+	assert.Nil(t, err)
+	var recording Recording
+	assert.Nil(t, json.Unmarshal(data, &recording))
+	assert.Equal(t, `{"name":"funny"}`, recording.Request.Body)
+	assert.Equal(t, "", http.Header(recording.Request.Headers).Get("Authorization"))
+	assert.Equal(t, 200, recording.Response.Status)
+
+	// the server is closed so the responses can only be the recorded ones
+	replay := NewFunny(WithHTTPClient(&http.Client{Transport: &Cassette{Dir: dir, Strict: true}}))
+	replay.Assign("base", base)
+	_, err = replay.Run(cassetteScript)
+	assert.Nil(t, err)
+	resp := replay.Lookup("resp").(map[string]Value)
+	assert.Equal(t, 200, resp["status"])
+	assert.Equal(t, map[string]Value{"name": "funny"}, resp["json"])
+	assert.Equal(t, "POST", resp["headers"].(map[string]Value)["x-method"])
+	assert.Equal(t, 404, replay.Lookup("missing").(map[string]Value)["status"])
+
+	_, err = replay.Run("http.request({\n  url = base + '/other'\n})")
+	assert.True(t, errors.Is(err, ErrNoRecording))
+}
+
+// stubTransport answers every request with status
+type stubTransport int
+
+func (s stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return RecordedResponse{Status: int(s)}.response(req)
+}
+
+func TestCassetteReplayNotStrict(t *testing.T) {
+	dir := t.TempDir()
+	log := new(bytes.Buffer)
+	fn := NewFunny(WithHTTPClient(&http.Client{Transport: &Cassette{Dir: dir, Next: stubTransport(204), Log: log}}))
+	v, err := fn.Run("return http.request({\n  url = 'http://example.com'\n}).status")
+	assert.Nil(t, err)
+	assert.Equal(t, 204, v)
+	// the request sent is not hidden
+	assert.Equal(t, "warning: no recorded response for GET http://example.com in "+dir+", sending it\n", log.String())
+}
@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/jerloo/funny"
+	prettyjson "github.com/jerloo/go-prettyjson"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const (
+	replPrompt         = "funny> "
+	replContinuePrompt = "   ... "
+)
+
+// replCommands the meta commands of repl and their help
+var replCommands = [][2]string{
+	{":load", ":load file   run the file in the current session"},
+	{":vars", ":vars        show the variables defined"},
+	{":reset", ":reset       start a new session, forgetting all variables"},
+	{":help", ":help        show this help"},
+	{":quit", ":quit        exit, or press ctrl-d"},
+}
+
+// replCmd represents the repl command
+var replCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Repl runs funny code interactively, printing the value of every expression.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		r := newRepl(os.Stdout)
+		fd := int(os.Stdin.Fd())
+		if term.IsTerminal(fd) {
+			r.interactive(fd)
+		} else {
+			r.script(os.Stdin)
+		}
+	},
+}
+
+// repl the state of an interactive session
+type repl struct {
+	fn  *funny.Funny
+	out io.Writer
+	// pending the lines of the code not complete yet
+	pending []string
+	// quit whether :quit is given
+	quit bool
+}
+
+func newRepl(out io.Writer) *repl {
+	r := &repl{
+		out: out,
+	}
+	r.reset()
+	return r
+}
+
+// reset start a new interpreter
+func (r *repl) reset() {
+	r.fn = funny.NewFunny(options()...)
+	r.fn.Stdout = r.out
+}
+
+// interactive read lines from terminal fd with history and completion. The terminal is
+// in raw mode only while reading, so ctrl-c interrupts the code running
+func (r *repl) interactive(fd int) {
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, r.out}, replPrompt)
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := r.complete(line, pos)
+		if len(candidates) > 1 && newLine == line {
+			fmt.Fprintln(t, strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+	fmt.Fprintf(r.out, "funny %s, :help for help\n", funny.VERSION)
+	for !r.quit {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			printError(err)
+			return
+		}
+		line, err := t.ReadLine()
+		_ = term.Restore(fd, state)
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			return
+		}
+		if err != nil {
+			printError(err)
+			return
+		}
+		r.feed(line)
+		if len(r.pending) > 0 {
+			t.SetPrompt(replContinuePrompt)
+		} else {
+			t.SetPrompt(replPrompt)
+		}
+	}
+}
+
+// script read lines from in without prompts, like input piped to repl
+func (r *repl) script(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for !r.quit && scanner.Scan() {
+		r.feed(scanner.Text())
+	}
+	if len(r.pending) > 0 {
+		r.eval(strings.Join(r.pending, "\n"))
+	}
+}
+
+// feed take one line of input, the code is run once its brackets and strings are closed
+func (r *repl) feed(line string) {
+	if len(r.pending) == 0 {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			return
+		}
+		if strings.HasPrefix(trimmed, ":") {
+			r.command(trimmed)
+			return
+		}
+	}
+	r.pending = append(r.pending, line)
+	code := strings.Join(r.pending, "\n")
+	if incomplete(code) {
+		return
+	}
+	r.pending = nil
+	r.eval(code)
+}
+
+// command run meta command line
+func (r *repl) command(line string) {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ":load":
+		if len(fields) != 2 {
+			fmt.Fprintln(r.out, "usage: :load file")
+			return
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		result, err := r.fn.RunFileContext(ctx, fields[1])
+		if err != nil {
+			printError(err)
+			return
+		}
+		if result != nil {
+			r.print(result)
+		}
+	case ":vars":
+		vars := make(map[string]funny.Value)
+		for _, scope := range r.fn.Vars {
+			for name, v := range scope {
+				vars[name] = v
+			}
+		}
+		r.print(vars)
+	case ":reset":
+		r.reset()
+	case ":help":
+		for _, c := range replCommands {
+			fmt.Fprintln(r.out, c[1])
+		}
+	case ":quit", ":exit":
+		r.quit = true
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, :help for help\n", fields[0])
+	}
+}
+
+// eval run code and print its value if it is an expression
+func (r *repl) eval(code string) {
+	program, expression, err := parseInput(code)
+	if err != nil {
+		printError(err)
+		return
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := r.fn.RunContext(ctx, program)
+	if err != nil {
+		printError(err)
+		return
+	}
+	if expression || result != nil {
+		r.print(result)
+	}
+}
+
+// print write v as pretty json
+func (r *repl) print(v funny.Value) {
+	bts, err := prettyjson.Marshal(v)
+	if err != nil {
+		fmt.Fprintln(r.out, v)
+		return
+	}
+	fmt.Fprintln(r.out, string(bts))
+}
+
+// parseInput parse code typed into repl, expression is true if the program returns the value
+// of code since it is an expression rather than statements
+func parseInput(code string) (program *funny.Program, expression bool, err error) {
+	block, err := parse("", code)
+	if err == nil && !expressionLike(block) {
+		return &funny.Program{Statements: block}, false, nil
+	}
+	// expressions like 1 + 2 are not statements, run them as return statements
+	returned, returnErr := parse("return ", code)
+	if returnErr == nil && len(statements(returned)) == 1 {
+		if _, ok := statements(returned)[0].(*funny.Return); ok {
+			// the value of a function call is printed only if it is not nil, like echoln()
+			call := false
+			if err == nil && len(statements(block)) == 1 {
+				_, call = statements(block)[0].(*funny.FunctionCall)
+			}
+			return &funny.Program{Statements: returned}, !call, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &funny.Program{Statements: block}, false, nil
+}
+
+// parse parse code after prefix, the columns are the ones in code, and the panics of the
+// lexer are returned as error
+func parse(prefix, code string) (block *funny.Block, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+				return
+			}
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	parser := funny.NewParser([]byte(prefix+code), "")
+	parser.Lexer.CurrentPos.Col = -len(prefix)
+	return parser.Parse()
+}
+
+// statements the statements of block not counting new lines and comments
+func statements(block *funny.Block) []funny.Statement {
+	var items []funny.Statement
+	for _, item := range block.Statements {
+		switch item.(type) {
+		case *funny.NewLine, *funny.Comment:
+		default:
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// expressionLike whether block is nothing or a single expression like a function call,
+// whose value should be printed
+func expressionLike(block *funny.Block) bool {
+	items := statements(block)
+	if len(items) == 0 {
+		return true
+	}
+	if len(items) > 1 {
+		return false
+	}
+	switch items[0].(type) {
+	case *funny.FunctionCall, *funny.Field, *funny.ListAccess, *funny.Slice:
+		return true
+	}
+	return false
+}
+
+// incomplete whether code has brackets or strings not closed yet
+func incomplete(code string) bool {
+	depth := 0
+	for index := 0; index < len(code); index++ {
+		switch ch := code[index]; ch {
+		case '/':
+			if index+1 < len(code) && code[index+1] == '/' {
+				for index < len(code) && code[index] != '\n' {
+					index++
+				}
+			}
+		case '\'', '"':
+			delimiter := string(ch)
+			if strings.HasPrefix(code[index:], strings.Repeat(delimiter, 3)) {
+				delimiter = strings.Repeat(delimiter, 3)
+			}
+			index += len(delimiter)
+			for ; ; index++ {
+				if index >= len(code) {
+					return true
+				}
+				if code[index] == '\\' {
+					index++
+					continue
+				}
+				if strings.HasPrefix(code[index:], delimiter) {
+					index += len(delimiter) - 1
+					break
+				}
+			}
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// complete the word before pos in line, candidates are the names of builtins, variables,
+// fields of dict variables and meta commands starting with the word
+func (r *repl) complete(line string, pos int) (newLine string, newPos int, candidates []string) {
+	start := pos
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	word := line[start:pos]
+	if strings.HasPrefix(line, ":") && !strings.Contains(line[:pos], " ") {
+		start, word = 0, line[:pos]
+		for _, c := range replCommands {
+			if strings.HasPrefix(c[0], word) {
+				candidates = append(candidates, c[0])
+			}
+		}
+	} else if word != "" {
+		candidates = r.names(word)
+	}
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+	completion := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	return line[:start] + completion + line[pos:], start + len(completion), candidates
+}
+
+// names the builtin functions, variables and fields of dict variables starting with prefix
+func (r *repl) names(prefix string) []string {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+	for _, name := range r.fn.Builtins.Names() {
+		add(name)
+	}
+	for _, scope := range r.fn.Vars {
+		for name, v := range scope {
+			add(name)
+			if dict, ok := v.(map[string]funny.Value); ok && strings.HasPrefix(prefix, name+".") {
+				for key := range dict {
+					add(name + "." + key)
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '.' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplIncomplete(t *testing.T) {
+	assert.False(t, incomplete("x = 1"))
+	assert.True(t, incomplete("f(a) {"))
+	assert.True(t, incomplete("x = [1,\n2"))
+	assert.False(t, incomplete("x = [1,\n2]"))
+	assert.False(t, incomplete("x = '{'"))
+	assert.False(t, incomplete("x = 1 // {"))
+	assert.True(t, incomplete("x = '''multi"))
+	assert.False(t, incomplete("x = '''multi\n'''"))
+	assert.False(t, incomplete("x = 'it\\'s'"))
+}
+
+func TestReplScript(t *testing.T) {
+	out := new(bytes.Buffer)
+	r := newRepl(out)
+	r.script(strings.NewReader(`
+x = 1
+x + 2
+add(a, b) {
+  return a + b
+}
+add(x, 4)
+echoln('hi')
+:reset
+:vars
+:quit
+x = 2
+`))
+	assert.Equal(t, "3\n5\nhi\n{}\n", out.String())
+}
+
+func TestReplComplete(t *testing.T) {
+	r := newRepl(new(bytes.Buffer))
+	r.eval("handlers = {\nonResponse(r) {\n}\n}")
+
+	line, pos, candidates := r.complete("x = strj", 8)
+	assert.Equal(t, "x = strjoin", line)
+	assert.Equal(t, 11, pos)
+	assert.Equal(t, []string{"strjoin"}, candidates)
+
+	line, _, candidates = r.complete("str", 3)
+	assert.Equal(t, "str", line)
+	assert.Equal(t, []string{"str", "strjoin", "strsplit"}, candidates)
+
+	line, _, _ = r.complete("handlers.on", 11)
+	assert.Equal(t, "handlers.onResponse", line)
+
+	line, _, _ = r.complete(":lo", 3)
+	assert.Equal(t, ":load", line)
+}
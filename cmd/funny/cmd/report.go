@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jerloo/funny"
+)
+
+var reports []string
+
+// reportTarget one --report flag, the results are written to stdout if path is empty or -
+type reportTarget struct {
+	format   string
+	reporter funny.Reporter
+	path     string
+}
+
+// parseReports parse the --report flags like junit=out.xml or tap
+func parseReports() ([]reportTarget, error) {
+	var targets []reportTarget
+	for _, report := range reports {
+		format, path := report, ""
+		if index := strings.Index(report, "="); index >= 0 {
+			format, path = report[:index], report[index+1:]
+		}
+		reporter, ok := funny.REPORTERS[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown report format %s, should be one of %s", format, strings.Join(funny.ReporterNames(), ", "))
+		}
+		targets = append(targets, reportTarget{
+			format:   format,
+			reporter: reporter,
+			path:     path,
+		})
+	}
+	return targets, nil
+}
+
+// toStdout whether any of targets writes to stdout
+func toStdout(targets []reportTarget) bool {
+	for _, target := range targets {
+		if target.path == "" || target.path == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// consoleOut where to print the human readable output, stderr if a report is written to
+// stdout so it can be piped
+func consoleOut(targets []reportTarget) io.Writer {
+	if toStdout(targets) {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// writeReports write results to every target
+func writeReports(targets []reportTarget, results []funny.TestResult) error {
+	for _, target := range targets {
+		if target.path == "" || target.path == "-" {
+			if err := target.reporter.Report(os.Stdout, results); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.Create(target.path)
+		if err != nil {
+			return err
+		}
+		err = target.reporter.Report(f, results)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("write %s report %s: %w", target.format, target.path, err)
+		}
+	}
+	return nil
+}
@@ -16,10 +16,20 @@ limitations under the License.
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/jerloo/funny"
+	"github.com/jerloo/funny/compiler"
 	"github.com/spf13/cobra"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -28,6 +38,12 @@ import (
 
 var cfgFile string
 var debug bool
+var limits funny.Limits
+var sandbox bool
+var capabilities funny.Capabilities
+var httpRecord string
+var httpReplay string
+var httpStrict bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -40,12 +56,34 @@ var rootCmd = &cobra.Command{
 		if len(args) == 1 {
 			filename := args[0]
 			if _, err := os.Stat(filename); err != nil {
-				fmt.Printf("file not found %s\n", filename)
-				return
+				fmt.Fprintf(os.Stderr, "file not found %s\n", filename)
+				os.Exit(1)
+			}
+			targets, err := parseReports()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			fn := funny.NewFunny(options()...)
+			fn.Assign("debug", debug)
+			output := new(bytes.Buffer)
+			if len(targets) > 0 {
+				fn.Stdout = io.MultiWriter(consoleOut(targets), output)
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			start := time.Now()
+			_, err = fn.RunFileContext(ctx, filename)
+			if len(targets) > 0 {
+				if err := writeReports(targets, []funny.TestResult{scriptResult(filename, time.Since(start), err, output.String())}); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(2)
+				}
+			}
+			if err != nil {
+				printError(err)
+				os.Exit(1)
 			}
-			funny := funny.NewFunny()
-			funny.Assign("debug", debug)
-			funny.RunFile(filename)
 		} else {
 			err := cmd.Usage()
 			if err != nil {
@@ -55,6 +93,64 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// options the interpreter options given by flags, builtins are sandboxed by the capabilities
+// if --sandbox or any --allow-* flag is given, and http requests are recorded or replayed
+// by --http-record or --http-replay
+func options() []funny.Option {
+	options := []funny.Option{
+		funny.WithLimits(limits),
+		// run the scripts by the bytecode vm
+		funny.WithEngine(compiler.Engine{}),
+	}
+	c := capabilities
+	if sandbox || c.Root != "" || c.Run || len(c.Read)+len(c.Write)+len(c.Net)+len(c.Env) > 0 {
+		options = append(options, funny.WithCapabilities(&c))
+	}
+	if httpRecord != "" && httpReplay != "" {
+		fmt.Fprintln(os.Stderr, "only one of --http-record and --http-replay can be given")
+		os.Exit(2)
+	}
+	if httpRecord != "" || httpReplay != "" {
+		cassette := &funny.Cassette{
+			Dir:    httpReplay,
+			Strict: httpStrict,
+		}
+		if httpRecord != "" {
+			cassette.Dir, cassette.Record = httpRecord, true
+		}
+		options = append(options, funny.WithHTTPClient(&http.Client{Transport: cassette}))
+	}
+	return options
+}
+
+// scriptResult the result of running script filename for reporters, which is failed if
+// err is not nil
+func scriptResult(filename string, duration time.Duration, err error, output string) funny.TestResult {
+	abs, _ := filepath.Abs(filename)
+	result := funny.TestResult{
+		File:     filename,
+		Name:     strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		Position: funny.Position{File: abs},
+		Status:   funny.TestPassed,
+		Duration: duration,
+		Err:      err,
+		Output:   output,
+	}
+	if err != nil {
+		result.Status = funny.TestFailed
+	}
+	return result
+}
+
+// printError print error and the funny call stack if any to stderr
+func printError(err error) {
+	fmt.Fprint(os.Stderr, strings.TrimRight(err.Error(), "\n"), "\n")
+	var fre *funny.FunnyRuntimeError
+	if errors.As(err, &fre) {
+		fmt.Fprint(os.Stderr, fre.StackTrace())
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -77,6 +173,20 @@ func init() {
 	// when this action is called directly.
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug mode")
+	rootCmd.PersistentFlags().IntVar(&limits.MaxSteps, "max-steps", 0, "most statements to run, 0 for unlimited")
+	rootCmd.PersistentFlags().IntVar(&limits.MaxDepth, "max-depth", 0, fmt.Sprintf("most nested function calls, 0 for %d and -1 for unlimited", funny.DefaultMaxDepth))
+	rootCmd.PersistentFlags().DurationVar(&limits.Timeout, "timeout", 0, "most time to run like 30s, 0 for unlimited")
+	rootCmd.PersistentFlags().BoolVar(&sandbox, "sandbox", false, "deny file, network, shell and env access unless allowed by --allow-* flags")
+	rootCmd.PersistentFlags().StringVar(&capabilities.Root, "root", "", "directory no file outside of it can be accessed, implies --sandbox")
+	rootCmd.PersistentFlags().StringSliceVar(&capabilities.Read, "allow-read", nil, "files or directories can be read, * for all, implies --sandbox")
+	rootCmd.PersistentFlags().StringSliceVar(&capabilities.Write, "allow-write", nil, "files or directories can be written, * for all, implies --sandbox")
+	rootCmd.PersistentFlags().StringSliceVar(&capabilities.Net, "allow-net", nil, "hosts can be connected like example.com:443 or *.example.com, * for all, implies --sandbox")
+	rootCmd.PersistentFlags().BoolVar(&capabilities.Run, "allow-run", false, "allow sh to run commands, implies --sandbox")
+	rootCmd.PersistentFlags().StringSliceVar(&capabilities.Env, "allow-env", nil, "environment variables can be read, * for all, implies --sandbox")
+	rootCmd.PersistentFlags().StringVar(&httpRecord, "http-record", "", "send http requests and save the responses as JSON files into the directory")
+	rootCmd.PersistentFlags().StringVar(&httpReplay, "http-replay", "", "answer http requests by the responses saved in the directory by --http-record, the ones not saved are sent with a warning unless --http-strict is given")
+	rootCmd.PersistentFlags().BoolVar(&httpStrict, "http-strict", false, "fail the http requests not saved when using --http-replay, rather than sending them")
+	rootCmd.PersistentFlags().StringArrayVar(&reports, "report", nil, fmt.Sprintf("write the results like junit=out.xml or tap to stdout, format is one of %s", strings.Join(funny.ReporterNames(), ", ")))
 }
 
 // initConfig reads in config file and ENV variables if set.
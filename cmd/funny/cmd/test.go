@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jerloo/funny"
+	"github.com/spf13/cobra"
+)
+
+var testRun string
+
+// testCmd represents the test command
+var testCmd = &cobra.Command{
+	Use:   "test [dirs or files...]",
+	Short: "Test runs the test_* functions and test('name') blocks of *_test.funny files.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		runner := &funny.TestRunner{
+			New: func() *funny.Funny {
+				return funny.NewFunny(options()...)
+			},
+		}
+		if testRun != "" {
+			filter, err := regexp.Compile(testRun)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid --run: %s\n", err)
+				os.Exit(2)
+			}
+			runner.Filter = filter
+		}
+		targets, err := parseReports()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		files, err := funny.FindTestFiles(args...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		results, ok := runTests(ctx, consoleOut(targets), runner, files)
+		if err := writeReports(targets, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
+// runTests run the tests of files and print the results to out, it returns the results
+// and whether none of them failed. A file can not be parsed is a failed result
+func runTests(ctx context.Context, out io.Writer, runner *funny.TestRunner, files []string) ([]funny.TestResult, bool) {
+	start := time.Now()
+	var all []funny.TestResult
+	passed, failed, skipped := 0, 0, 0
+	for _, file := range files {
+		fileStart := time.Now()
+		results, err := runner.RunFile(ctx, file)
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL\t%s\n    %s\n", file, strings.TrimRight(err.Error(), "\n"))
+			all = append(all, funny.TestResult{
+				File:   file,
+				Name:   filepath.Base(file),
+				Status: funny.TestFailed,
+				Err:    err,
+			})
+			continue
+		}
+		all = append(all, results...)
+		fileFailed := false
+		for _, result := range results {
+			printTestResult(out, result)
+			switch result.Status {
+			case funny.TestPassed:
+				passed++
+			case funny.TestFailed:
+				failed++
+				fileFailed = true
+			case funny.TestSkipped:
+				skipped++
+			}
+		}
+		status := "ok"
+		if fileFailed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%.3fs\n", status, file, time.Since(fileStart).Seconds())
+	}
+	fmt.Fprintf(out, "\n%d passed, %d failed, %d skipped in %.3fs\n", passed, failed, skipped, time.Since(start).Seconds())
+	return all, failed == 0
+}
+
+// printTestResult print result like go test -v does, with the output of the test and the
+// position and source line of the failure
+func printTestResult(out io.Writer, result funny.TestResult) {
+	if result.Output != "" {
+		for _, line := range strings.Split(strings.TrimRight(result.Output, "\n"), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	}
+	fmt.Fprintf(out, "--- %s: %s (%.3fs)\n", strings.ToUpper(string(result.Status)), result.Name, result.Duration.Seconds())
+	if result.Err == nil {
+		return
+	}
+	var fre *funny.FunnyRuntimeError
+	if errors.As(result.Err, &fre) {
+		fmt.Fprintf(out, "    %s:%d:%d: %s\n", relativePath(fre.Postion.File), fre.Postion.Line+1, fre.Postion.Col+1, strings.ReplaceAll(fre.Msg, "\n", "\n    "))
+	} else {
+		fmt.Fprintf(out, "    %s\n", strings.TrimRight(result.Err.Error(), "\n"))
+	}
+	if result.Source != "" {
+		fmt.Fprintf(out, "        %s\n", result.Source)
+	}
+}
+
+// relativePath file relative to the working directory if it is in it
+func relativePath(file string) string {
+	if !filepath.IsAbs(file) {
+		return file
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	testCmd.Flags().StringVar(&testRun, "run", "", "run only the tests whose names match the regular expression")
+}
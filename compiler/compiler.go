@@ -0,0 +1,667 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jerloo/funny"
+)
+
+// Instruction one operation of the instruction stream
+type Instruction struct {
+	Op Opcode
+	A  int32
+	B  int32
+}
+
+// Code the compiled instructions of a program or a function
+type Code struct {
+	Name       string
+	Parameters []string
+	Position   funny.Position
+
+	Instructions []Instruction
+	// Positions source position of every instruction, used by runtime errors
+	Positions []funny.Position
+	Constants []funny.Value
+	Names     []string
+	// Locals the variables of function in the order of their slots, parameters first
+	Locals []string
+	// Bindings the variables read by code
+	Bindings []*Binding
+	// This the binding of this, which calls look up methods in
+	This int
+}
+
+// Disassemble dump the instructions of code and the functions defined in it
+func (c *Code) Disassemble() string {
+	var b strings.Builder
+	var functions []*Code
+	name := c.Name
+	if name == "" {
+		name = "<main>"
+	}
+	fmt.Fprintf(&b, "%s(%s):\n", name, strings.Join(c.Parameters, ", "))
+	for index, in := range c.Instructions {
+		fmt.Fprintf(&b, "%4d %-22s", index, in.Op)
+		switch in.Op {
+		case OpStore, OpField:
+			fmt.Fprintf(&b, "%d (%s)", in.A, c.Names[in.A])
+		case OpLoad:
+			fmt.Fprintf(&b, "%d (%s)", in.A, c.Bindings[in.A])
+		case OpLoadLocal, OpStoreLocal:
+			fmt.Fprintf(&b, "%d (%s)", in.A, c.Locals[in.A])
+		case OpCall:
+			fmt.Fprintf(&b, "%d (%s) %d", in.A, c.Bindings[c.Constants[in.A].(*functionCall).Binding], in.B)
+		case OpCallMethod:
+			fmt.Fprintf(&b, "%d (%s) %d", in.A, c.Bindings[c.Constants[in.A].(*methodCall).Binding], in.B)
+		case OpConst, OpNot, OpBool, OpFieldKey, OpAssignField, OpIndex, OpSetIndex, OpSlice, OpFail:
+			constant := c.Constants[in.A]
+			if s, ok := constant.(funny.Statement); ok {
+				fmt.Fprintf(&b, "%d (%s)", in.A, s.String())
+			} else {
+				fmt.Fprintf(&b, "%d (%#v)", in.A, constant)
+			}
+		case OpClosure:
+			function := c.Constants[in.A].(*Code)
+			functions = append(functions, function)
+			fmt.Fprintf(&b, "%d (%s)", in.A, function.signature())
+		case OpJump, OpJumpIfFalse, OpJumpIfFalseOrPop, OpJumpIfTrueOrPop, OpNext, OpSetupTry,
+			OpList, OpDict, OpTemplate:
+			fmt.Fprintf(&b, "%d", in.A)
+		}
+		b.WriteString("\n")
+	}
+	for _, function := range functions {
+		b.WriteString("\n")
+		b.WriteString(function.Disassemble())
+	}
+	return b.String()
+}
+
+func (c *Code) signature() string {
+	name := c.Name
+	if name == "" {
+		name = funny.FN
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(c.Parameters, ", "))
+}
+
+const (
+	// blockLoop a for statement, break and continue jump to it
+	blockLoop = iota
+	// blockTry a try body or a catch body followed by finally, its handler is registered
+	blockTry
+	// blockFinally a finally body, the pending error is on the stack
+	blockFinally
+)
+
+// block the statement enclosing the instructions being compiled,
+// leaving it by return, break or continue needs cleaning up
+type block struct {
+	kind int
+	// next the instruction continue jumps to
+	next int
+	// breaks the jump instructions of break to patch
+	breaks []int
+	// finally the finally body of try to run before leaving it
+	finally *funny.Block
+}
+
+// functionCall the operand of OpCall
+type functionCall struct {
+	// Binding the binding of the function name
+	Binding int
+	// Call the call compiled, it is the current call of the interpreter when the function runs
+	Call *funny.FunctionCall
+}
+
+// methodCall the operand of OpCallMethod
+type methodCall struct {
+	// Binding the binding of the method name, used to find the function if the dict does not have it
+	Binding int
+	// Namespace the name of the dict, used to find the namespaced builtin if the dict is not defined
+	Namespace string
+	// Call the call compiled, it is the current call of the interpreter when the method runs
+	Call *funny.FunctionCall
+}
+
+// Compiler compiles the statements of a program or a function into code
+type Compiler struct {
+	code      *Code
+	blocks    []*block
+	names     map[string]int
+	constants map[funny.Value]int
+	bindings  map[string]int
+	scope     *scope
+	resolver  *resolver
+}
+
+// Compile compile the block parsed by funny.Parser into the code of a program. The variables
+// of functions are resolved to slots of their frames, and reading a name neither assigned
+// by the program nor reported by defined is an error. Names are not checked if defined is nil
+func Compile(block *funny.Block, defined func(name string) bool) (*Code, error) {
+	r := &resolver{
+		globals: make(map[string]bool),
+		defined: defined,
+	}
+	declare(block, func(name string) {
+		r.globals[name] = true
+	})
+	c := newCompiler("", nil, block.GetPosition(), r)
+	c.compileBlock(block)
+	c.emit(OpConst, c.constant(nil), 0, block.GetPosition())
+	c.emit(OpReturn, 0, 0, block.GetPosition())
+	if r.err != nil {
+		return nil, r.err
+	}
+	return c.code, nil
+}
+
+func newCompiler(name string, parameters []string, pos funny.Position, r *resolver) *Compiler {
+	return &Compiler{
+		code: &Code{
+			Name:       name,
+			Parameters: parameters,
+			Position:   pos,
+			This:       -1,
+		},
+		names:     make(map[string]int),
+		constants: make(map[funny.Value]int),
+		bindings:  make(map[string]int),
+		resolver:  r,
+	}
+}
+
+// emit append one instruction and return its index
+func (c *Compiler) emit(op Opcode, a, b int, pos funny.Position) int {
+	c.code.Instructions = append(c.code.Instructions, Instruction{
+		Op: op,
+		A:  int32(a),
+		B:  int32(b),
+	})
+	c.code.Positions = append(c.code.Positions, pos)
+	return len(c.code.Instructions) - 1
+}
+
+// patch set the jump target of instruction at index to the next instruction
+func (c *Compiler) patch(index int) {
+	c.code.Instructions[index].A = int32(len(c.code.Instructions))
+}
+
+func (c *Compiler) name(name string) int {
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+	c.code.Names = append(c.code.Names, name)
+	c.names[name] = len(c.code.Names) - 1
+	return c.names[name]
+}
+
+func (c *Compiler) constant(v funny.Value) int {
+	if index, ok := c.constants[v]; ok {
+		return index
+	}
+	c.code.Constants = append(c.code.Constants, v)
+	c.constants[v] = len(c.code.Constants) - 1
+	return c.constants[v]
+}
+
+// fail emit an instruction raises error with message at runtime
+func (c *Compiler) fail(message string, pos funny.Position) {
+	c.emit(OpFail, c.constant(message), 0, pos)
+}
+
+func (c *Compiler) pushBlock(b *block) {
+	c.blocks = append(c.blocks, b)
+}
+
+func (c *Compiler) popBlock() {
+	c.blocks = c.blocks[:len(c.blocks)-1]
+}
+
+// unwind emit the instructions leaving the blocks from the innermost one to the one at depth
+func (c *Compiler) unwind(depth int, pos funny.Position) {
+	for index := len(c.blocks) - 1; index >= depth; index-- {
+		switch b := c.blocks[index]; b.kind {
+		case blockTry:
+			c.emit(OpPopTry, 0, 0, pos)
+			if b.finally != nil {
+				// the finally body is compiled inline, only the blocks outside its try enclose it
+				blocks := c.blocks
+				c.blocks = append([]*block(nil), c.blocks[:index]...)
+				c.compileBlock(b.finally)
+				c.blocks = blocks
+			}
+		case blockFinally:
+			c.emit(OpPop, 0, 0, pos)
+		}
+	}
+}
+
+// loop find the depth of the innermost for statement, -1 if not in for statement
+func (c *Compiler) loop() int {
+	for index := len(c.blocks) - 1; index >= 0; index-- {
+		if c.blocks[index].kind == blockLoop {
+			return index
+		}
+	}
+	return -1
+}
+
+func (c *Compiler) compileBlock(block *funny.Block) {
+	if block == nil {
+		return
+	}
+	for _, item := range block.Statements {
+		c.compileStatement(item)
+	}
+}
+
+func (c *Compiler) compileStatement(item funny.Statement) {
+	pos := item.GetPosition()
+	switch item.(type) {
+	case *funny.NewLine, *funny.Comment:
+	default:
+		c.emit(OpStep, 0, 0, pos)
+	}
+	switch item := item.(type) {
+	case *funny.Assign:
+		switch target := item.Target.(type) {
+		case *funny.Variable:
+			c.compileExpression(item.Value)
+			c.store(target.Name, pos)
+		case *funny.Field:
+			c.compileExpression(item.Value)
+			if !c.compileAssignField(target, func() {
+				c.load(target.Variable.Name, false, target.Position)
+			}) {
+				return
+			}
+			c.store(target.Variable.Name, target.Position)
+		case *funny.ListAccess:
+			c.compileExpression(item.Value)
+			c.compileExpression(target.List)
+			c.compileExpression(target.Index)
+			c.emit(OpSetIndex, c.constant(target), 0, target.Position)
+		default:
+			c.fail("invalid assignment", pos)
+		}
+	case *funny.IFStatement:
+		c.compileIf(item)
+	case *funny.FORStatement:
+		c.compileFor(item)
+	case *funny.TryStatement:
+		c.compileTry(item)
+	case *funny.FunctionCall, *funny.Field, *funny.ListAccess, *funny.Slice:
+		c.compileExpression(item)
+		c.emit(OpPop, 0, 0, pos)
+	case *funny.ImportFunctionCall:
+		for _, d := range item.Block.Statements {
+			switch d := d.(type) {
+			case *funny.Assign:
+				if t, ok := d.Target.(*funny.Variable); ok {
+					c.compileExpression(d.Value)
+					c.store(t.Name, d.Position)
+				} else {
+					c.fail("block assignments must be variable", pos)
+				}
+			case *funny.NewLine, *funny.Comment:
+			case *funny.Function:
+				c.emit(OpClosure, c.constant(c.compileFunction(d)), 0, d.Position)
+				c.store(d.Name, d.Position)
+			default:
+				c.fail("module must only contains assignment and func", pos)
+			}
+		}
+	case *funny.Return:
+		c.compileExpression(item.Value)
+		// the errors raised by the caller after it returns are at the value like the
+		// interpreter reports them, unless finally bodies run after the value
+		current, at := 1, tail(item.Value)
+		for _, b := range c.blocks {
+			if b.finally != nil {
+				current, at = 0, pos
+			}
+		}
+		c.unwind(0, pos)
+		c.emit(OpReturn, current, 0, at)
+	case *funny.Break:
+		depth := c.loop()
+		if depth < 0 {
+			c.unwind(0, pos)
+			c.fail("break outside of for statement", pos)
+			return
+		}
+		c.unwind(depth+1, pos)
+		loop := c.blocks[depth]
+		loop.breaks = append(loop.breaks, c.emit(OpJump, 0, 0, pos))
+	case *funny.Continue:
+		depth := c.loop()
+		if depth < 0 {
+			c.unwind(0, pos)
+			c.fail("continue outside of for statement", pos)
+			return
+		}
+		c.unwind(depth+1, pos)
+		c.emit(OpJump, c.blocks[depth].next, 0, pos)
+	case *funny.Function:
+		c.emit(OpClosure, c.constant(c.compileFunction(item)), 0, pos)
+		c.store(item.Name, pos)
+	case *funny.NewLine, *funny.Comment:
+	default:
+		c.fail(fmt.Sprintf("invalid statement [%s]", item.String()), pos)
+	}
+}
+
+func (c *Compiler) compileIf(item *funny.IFStatement) {
+	c.compileExpression(item.Condition)
+	jumpElse := c.emit(OpJumpIfFalse, 0, 0, item.Position)
+	c.compileBlock(item.Body)
+	if item.ElseIf == nil && item.Else == nil {
+		c.patch(jumpElse)
+		return
+	}
+	jumpEnd := c.emit(OpJump, 0, 0, item.Position)
+	c.patch(jumpElse)
+	if elseIf, ok := item.ElseIf.(*funny.IFStatement); ok {
+		c.compileIf(elseIf)
+	} else {
+		c.compileBlock(item.Else)
+	}
+	c.patch(jumpEnd)
+}
+
+// compileFor compile for statement, the iterator stays on the stack until the loop ends
+func (c *Compiler) compileFor(item *funny.FORStatement) {
+	pos := item.Position
+	itemName, ok := item.CurrentItem.(*funny.Variable)
+	if !ok {
+		c.fail(fmt.Sprintf("for item must be a variable given [%s]", item.CurrentItem.String()), pos)
+		return
+	}
+	c.compileExpression(item.Iterable.Value)
+	c.emit(OpIter, 0, 0, pos)
+	loop := &block{
+		kind: blockLoop,
+		next: c.emit(OpNext, 0, 0, pos),
+	}
+	c.store(item.CurrentIndex.Name, pos)
+	c.store(itemName.Name, pos)
+	c.pushBlock(loop)
+	c.compileBlock(&item.Block)
+	c.popBlock()
+	c.emit(OpJump, loop.next, 0, pos)
+	for _, index := range loop.breaks {
+		c.patch(index)
+	}
+	c.emit(OpPop, 0, 0, pos)
+	c.patch(loop.next)
+}
+
+// compileTry compile try statement, the handler of body jumps to catch, or to finally if there
+// is no catch. Errors in catch go to finally, and finally raises the pending error at last
+func (c *Compiler) compileTry(item *funny.TryStatement) {
+	pos := item.Position
+	handler := c.emit(OpSetupTry, 0, 0, pos)
+	c.pushBlock(&block{kind: blockTry, finally: item.Finally})
+	c.compileBlock(item.Body)
+	c.popBlock()
+	c.emit(OpPopTry, 0, 0, pos)
+	if item.Catch != nil {
+		if item.Finally != nil {
+			c.emit(OpConst, c.constant(nil), 0, pos)
+		}
+		jumpEnd := c.emit(OpJump, 0, 0, pos)
+		c.patch(handler)
+		if item.Error != nil {
+			c.emit(OpCatch, 0, 0, pos)
+			c.store(item.Error.Name, pos)
+		} else {
+			c.emit(OpPop, 0, 0, pos)
+		}
+		if item.Finally == nil {
+			c.compileBlock(item.Catch)
+			c.patch(jumpEnd)
+			return
+		}
+		handler = c.emit(OpSetupTry, 0, 0, pos)
+		c.pushBlock(&block{kind: blockTry, finally: item.Finally})
+		c.compileBlock(item.Catch)
+		c.popBlock()
+		c.emit(OpPopTry, 0, 0, pos)
+		c.emit(OpConst, c.constant(nil), 0, pos)
+		c.patch(jumpEnd)
+	} else {
+		c.emit(OpConst, c.constant(nil), 0, pos)
+	}
+	c.patch(handler)
+	c.pushBlock(&block{kind: blockFinally})
+	c.compileBlock(item.Finally)
+	c.popBlock()
+	c.emit(OpEndFinally, 0, 0, pos)
+}
+
+// tail the position of the last part of expression evaluated, the interpreter reports the
+// errors of operators there
+func tail(expression funny.Statement) funny.Position {
+	if b, ok := expression.(*funny.BinaryExpression); ok && b.Operator.Kind != funny.NAME {
+		return tail(b.Right)
+	}
+	return expression.GetPosition()
+}
+
+// compileFunction compile function
+func (c *Compiler) compileFunction(item *funny.Function) *Code {
+	var parameters []string
+	for _, p := range item.Parameters {
+		parameters = append(parameters, p.String())
+	}
+	sub := newCompiler(item.Name, parameters, item.Position, c.resolver)
+	sub.scope = newScope(c.scope, parameters, item.Body)
+	sub.code.Locals = sub.scope.locals()
+	sub.compileBlock(item.Body)
+	sub.emit(OpConst, sub.constant(nil), 0, item.Position)
+	sub.emit(OpReturn, 0, 0, item.Position)
+	return sub.code
+}
+
+var binaryOperators = map[string]Opcode{
+	funny.PLUS:      OpAdd,
+	funny.MINUS:     OpSub,
+	funny.TIMES:     OpMul,
+	funny.DEVIDE:    OpDiv,
+	funny.GT:        OpGt,
+	funny.GTE:       OpGte,
+	funny.LT:        OpLt,
+	funny.LTE:       OpLte,
+	funny.DOUBLE_EQ: OpEq,
+	funny.NOTEQ:     OpNotEq,
+	funny.IN:        OpIn,
+	funny.NOTIN:     OpNotIn,
+}
+
+func (c *Compiler) compileExpression(expression funny.Statement) {
+	pos := expression.GetPosition()
+	switch item := expression.(type) {
+	case *funny.BinaryExpression:
+		operator := item.Operator.Kind
+		if operator == funny.NAME {
+			operator = item.Operator.Data
+		}
+		switch operator {
+		case funny.AND, funny.OR:
+			name := c.constant(operator)
+			c.compileExpression(item.Left)
+			c.emit(OpBool, name, 0, item.Left.GetPosition())
+			jump := OpJumpIfFalseOrPop
+			if operator == funny.OR {
+				jump = OpJumpIfTrueOrPop
+			}
+			end := c.emit(jump, 0, 0, pos)
+			c.compileExpression(item.Right)
+			c.emit(OpBool, name, 0, item.Right.GetPosition())
+			c.patch(end)
+			return
+		}
+		op, ok := binaryOperators[operator]
+		if !ok {
+			c.fail(fmt.Sprintf("only support [+] [-] [*] [/] [>] [>=] [==] [!=] [<=] [<] [in] [not in] [and] [or] given [%s]", item.Operator.Data), pos)
+			return
+		}
+		c.compileExpression(item.Left)
+		c.compileExpression(item.Right)
+		c.emit(op, 0, 0, tail(item.Right))
+	case *funny.UnaryExpression:
+		c.compileExpression(item.Expression)
+		if item.Operator.Kind == funny.MINUS {
+			c.emit(OpNeg, 0, 0, pos)
+		} else {
+			c.emit(OpNot, c.constant(item.Operator.Data), 0, item.Expression.GetPosition())
+		}
+	case *funny.List:
+		for _, v := range item.Values {
+			c.compileExpression(v)
+		}
+		c.emit(OpList, len(item.Values), 0, pos)
+	case *funny.Block:
+		c.compileDict(item.Statements, "dict struct must only contains assignment and func", pos)
+	case *funny.Boolen:
+		c.emit(OpConst, c.constant(item.Value), 0, pos)
+	case *funny.Literal:
+		c.emit(OpConst, c.constant(item.Value), 0, pos)
+	case *funny.StringExpression:
+		c.emit(OpConst, c.constant(item.Value), 0, pos)
+	case *funny.Variable:
+		c.load(item.Name, true, pos)
+	case *funny.Function:
+		c.emit(OpClosure, c.constant(c.compileFunction(item)), 0, pos)
+	case *funny.FunctionCall:
+		for _, p := range item.Parameters {
+			c.compileExpression(p)
+		}
+		c.check("function [%s] not defined", item.Name, pos)
+		call := &functionCall{
+			Binding: c.call(item.Name),
+			Call:    item,
+		}
+		c.emit(OpCall, c.constant(call), len(item.Parameters), pos)
+	case *funny.Field:
+		c.load(item.Variable.Name, false, pos)
+		c.compileField(item)
+	case *funny.ListAccess:
+		c.compileExpression(item.List)
+		c.compileExpression(item.Index)
+		c.emit(OpIndex, c.constant(item), 0, pos)
+	case *funny.Slice:
+		c.compileExpression(item.List)
+		for _, bound := range []funny.Statement{item.Start, item.End} {
+			if bound == nil {
+				c.emit(OpConst, c.constant(nil), 0, pos)
+			} else {
+				c.compileExpression(bound)
+			}
+		}
+		c.emit(OpSlice, c.constant(item), 0, pos)
+	case *funny.StringTemplate:
+		for _, part := range item.Parts {
+			c.compileExpression(part)
+		}
+		c.emit(OpTemplate, len(item.Parts), 0, pos)
+	case *funny.ImportFunctionCall:
+		c.compileDict(item.Block.Statements, "module must only contains assignment and func", pos)
+	case *funny.SubExpression:
+		c.compileExpression(item.Expression)
+	default:
+		c.fail(fmt.Sprintf("eval expression error: [%s]", expression.String()), pos)
+	}
+}
+
+// compileAssignField compile setting field of the dict loadRoot pushes to the value on the
+// stack, the dict is left on the stack, the dicts of the nested fields like d.a.b are set in
+// turn. It is false if the field can not be assigned
+func (c *Compiler) compileAssignField(field *funny.Field, loadRoot func()) bool {
+	switch key := field.Value.(type) {
+	case *funny.StringExpression:
+		loadRoot()
+		c.emit(OpConst, c.constant(key.Value), 0, field.Position)
+	case *funny.Variable:
+		loadRoot()
+		c.load(key.Name, false, key.Position)
+	case *funny.Field:
+		if !c.compileAssignField(key, func() {
+			loadRoot()
+			c.emit(OpField, c.name(key.Variable.Name), 0, key.Position)
+		}) {
+			return false
+		}
+		loadRoot()
+		c.emit(OpConst, c.constant(key.Variable.Name), 0, key.Position)
+	default:
+		c.fail(fmt.Sprintf("invalid field assignment [%s]", field.String()), field.Position)
+		return false
+	}
+	c.emit(OpAssignField, c.constant(field), 0, field.Position)
+	return true
+}
+
+// compileField compile the value of field, the dict it belongs to is on the stack
+func (c *Compiler) compileField(field *funny.Field) {
+	pos := field.Position
+	switch v := field.Value.(type) {
+	case *funny.FunctionCall:
+		for _, p := range v.Parameters {
+			c.compileExpression(p)
+		}
+		m := &methodCall{
+			Binding:   c.call(v.Name),
+			Namespace: field.Variable.Name,
+			Call:      v,
+		}
+		c.emit(OpCallMethod, c.constant(m), len(v.Parameters), pos)
+	case *funny.StringExpression:
+		c.emit(OpField, c.name(v.Value), 0, pos)
+	case *funny.Variable:
+		c.load(v.Name, false, v.Position)
+		c.emit(OpFieldKey, c.constant(field), 0, pos)
+	case *funny.Field:
+		c.emit(OpField, c.name(v.Variable.Name), 0, pos)
+		c.compileField(v)
+	default:
+		c.fail(fmt.Sprintf("unknow type %v", v), pos)
+	}
+}
+
+// compileDict compile the assignments and functions of dict literal or module, the functions
+// see the other members when they are called as methods. Other statements fail with invalid
+func (c *Compiler) compileDict(statements []funny.Statement, invalid string, pos funny.Position) {
+	count := 0
+	for _, d := range statements {
+		switch d := d.(type) {
+		case *funny.Assign:
+			if t, ok := d.Target.(*funny.Variable); ok {
+				c.emit(OpConst, c.constant(t.Name), 0, d.Position)
+				c.compileExpression(d.Value)
+				count++
+			} else {
+				c.fail("block assignments must be variable", pos)
+			}
+		case *funny.NewLine, *funny.Comment:
+		case *funny.Function:
+			c.emit(OpConst, c.constant(d.Name), 0, d.Position)
+			c.emit(OpClosure, c.constant(c.compileFunction(d)), 0, d.Position)
+			count++
+		default:
+			c.fail(invalid, pos)
+		}
+	}
+	c.emit(OpDict, count, 0, pos)
+}
+
+// call bind the function named name called by code, and this where methods are found
+func (c *Compiler) call(name string) int {
+	if c.code.This < 0 {
+		c.code.This = c.bind("this", false)
+	}
+	return c.bind(name, false)
+}
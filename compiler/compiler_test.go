@@ -0,0 +1,296 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jerloo/funny"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileControlFlow(t *testing.T) {
+	scripts := []string{
+		`
+total = 0
+for index, item in [1, 2, 3, 4, 5] {
+  try {
+    if item == 2 {
+      continue
+    }
+    if item == 4 {
+      break
+    }
+    total = total + item
+  } finally {
+    total = total + 10
+  }
+}
+return total
+`,
+		`
+f() {
+  try {
+    return 1
+  } finally {
+    return 2
+  }
+}
+return f()
+`,
+		`
+log = []
+f() {
+  try {
+    throw('a')
+  } catch err {
+    log = append(log, err.message)
+    throw('b')
+  } finally {
+    log = append(log, 'finally')
+  }
+}
+try {
+  f()
+} catch err {
+  log = append(log, err.message)
+}
+return log
+`,
+		`
+for index, item in [1, 2] {
+  for i, j in [3, 4] {
+    if j == 4 {
+      break
+    }
+    result = [item, j]
+  }
+}
+return result
+`,
+		`
+f() {
+  break
+}
+try {
+  f()
+} catch err {
+  return err.message
+}
+`,
+		`
+counter() {
+  count = {
+    n = 0
+  }
+  return fn() {
+    count.n = count.n + 1
+    return count.n
+  }
+}
+c = counter()
+c()
+return [c(), map([1, 2], fn(x) { return x * 2 }), sort([3, 1, 2], fn(a, b) { return a > b })]
+`,
+		`
+obj = {
+  base = 10
+  add(n) {
+    return base + n + this.base
+  }
+}
+return obj.add(1)
+`,
+		`
+xs = [1, 2, 3]
+xs[-1] = 30
+d = {
+  items = xs
+}
+d.items[0] = 10
+return [xs[1:], d.items[0], "sum ${xs[0] + xs[2]}", 1 in xs, not (1 in xs) or false]
+`,
+		`
+a = 1
+a()
+`,
+		`
+if 1 {
+}
+`,
+	}
+	for _, script := range scripts {
+		assertSameScript(t, script)
+	}
+}
+
+func TestCompileRuntimeErrorPosition(t *testing.T) {
+	fn := funny.NewFunny()
+	_, err := New(fn).RunSource([]byte(`
+f(xs) {
+  return xs[5]
+}
+f([1])
+`), "")
+	fre, ok := err.(*funny.FunnyRuntimeError)
+	assert.True(t, ok)
+	assert.Equal(t, "list index 5 out of range with length 1", fre.Msg)
+	assert.Equal(t, 2, fre.Postion.Line)
+	assert.Equal(t, "f", fre.Stack[0].Name)
+}
+
+func TestDisassemble(t *testing.T) {
+	parser := funny.NewParser([]byte(`
+add(a, b) {
+  return a + b
+}
+echo(add(1, 2))
+`), "")
+	block, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	code, err := Compile(block, nil)
+	if err != nil {
+		panic(err)
+	}
+	s := code.Disassemble()
+	assert.True(t, strings.Contains(s, "(add) 2"), s)
+	assert.True(t, strings.Contains(s, "add(a, b):"), s)
+	assert.True(t, strings.Contains(s, "ADD"), s)
+}
+
+const fibScript = `
+fib(n) {
+  if n < 2 {
+    return n
+  }
+  return fib(n - 1) + fib(n - 2)
+}
+return fib(20)
+`
+
+func BenchmarkFibTreeWalker(b *testing.B) {
+	parser := funny.NewParser([]byte(fibScript), "")
+	block, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	for n := 0; n < b.N; n++ {
+		funny.NewFunny().Run(funny.Program{Statements: block})
+	}
+}
+
+func BenchmarkFibVM(b *testing.B) {
+	parser := funny.NewParser([]byte(fibScript), "")
+	block, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	code, err := Compile(block, nil)
+	if err != nil {
+		panic(err)
+	}
+	for n := 0; n < b.N; n++ {
+		New(funny.NewFunny()).Run(code)
+	}
+}
+
+func TestCompileNamespacedBuiltin(t *testing.T) {
+	fn := funny.NewFunny()
+	err := fn.RegisterFunction("text.upper", func(fn *funny.Funny, args []funny.Value) funny.Value {
+		return strings.ToUpper(args[0].(string))
+	})
+	assert.Nil(t, err)
+	r, err := New(fn).RunSource([]byte(`
+f(s) {
+  return text.upper(s)
+}
+return f('abc')
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ABC", r)
+
+	_, err = New(funny.NewFunny()).RunSource([]byte("return text.upper('abc')"), "")
+	assert.Equal(t, "variable [text] not defined", err.(*funny.FunnyRuntimeError).Msg)
+}
+
+// TestCompileConcurrentRun is meant to be run with -race, the vms share the compiled code
+func TestCompileConcurrentRun(t *testing.T) {
+	block, err := funny.NewParser([]byte(fibScript), "").Parse()
+	if err != nil {
+		panic(err)
+	}
+	code, err := Compile(block, nil)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for index := 0; index < 8; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := New(funny.NewFunny()).Run(code)
+			assert.Nil(t, err)
+			assert.Equal(t, 6765, r)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCompileLimits(t *testing.T) {
+	fn := funny.NewFunny(funny.WithLimits(funny.Limits{
+		MaxSteps: 8,
+	}))
+	_, err := New(fn).RunSource([]byte(`
+total = 0
+for index, item in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10] {
+  total = total + item
+}
+`), "")
+	assert.True(t, errors.Is(err, funny.ErrStepLimit))
+	assert.Equal(t, 21, fn.Vars[0]["total"])
+
+	r, err := New(funny.NewFunny()).RunSource([]byte(`
+f(n) {
+  return f(n + 1)
+}
+try {
+  f(0)
+} catch err {
+  return err.message
+}
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "call depth limit 10000 exceeded", r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	block, err := funny.NewParser([]byte(fibScript), "").Parse()
+	if err != nil {
+		panic(err)
+	}
+	code, err := Compile(block, nil)
+	assert.Nil(t, err)
+	_, err = New(funny.NewFunny()).RunContext(ctx, code)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestCompileCall(t *testing.T) {
+	vm := New(funny.NewFunny())
+	_, err := vm.RunSource([]byte(`
+add(a, b) {
+  return a + b
+}
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"add"}, vm.Funny.Functions())
+
+	r, err := vm.Call("add", 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r)
+
+	_, err = vm.Call("add", 1, "a")
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(vm.stack))
+}
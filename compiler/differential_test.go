@@ -0,0 +1,279 @@
+package compiler
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jerloo/funny"
+	"github.com/stretchr/testify/assert"
+)
+
+// testScripts collect the funny scripts run by the tests of the interpreter in file:
+// literals assigned to data, given to RunSingle or Run, and the code of test cases
+func testScripts(t *testing.T, file string) []string {
+	tree, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scripts []string
+	add := func(e ast.Expr) {
+		if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			scripts = append(scripts, s)
+		}
+	}
+	ast.Inspect(tree, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for index, lhs := range n.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && ident.Name == "data" && index < len(n.Rhs) {
+					add(n.Rhs[index])
+				}
+			}
+		case *ast.CallExpr:
+			switch fun := n.Fun.(type) {
+			case *ast.Ident:
+				if fun.Name == "RunSingle" && len(n.Args) == 1 {
+					add(n.Args[0])
+				}
+			case *ast.SelectorExpr:
+				if fun.Sel.Name == "Run" && len(n.Args) == 1 {
+					add(n.Args[0])
+				}
+			}
+		case *ast.CompositeLit:
+			array, ok := n.Type.(*ast.ArrayType)
+			if !ok {
+				return true
+			}
+			st, ok := array.Elt.(*ast.StructType)
+			if !ok || len(st.Fields.List) == 0 || st.Fields.List[0].Names[0].Name != "code" {
+				return true
+			}
+			for _, elt := range n.Elts {
+				if item, ok := elt.(*ast.CompositeLit); ok && len(item.Elts) > 0 {
+					add(item.Elts[0])
+				}
+			}
+		}
+		return true
+	})
+	return scripts
+}
+
+// normalize replace functions with a placeholder, they are different types in the two implementations
+func normalize(v funny.Value) funny.Value {
+	switch v := v.(type) {
+	case funny.Callable, funny.BuiltinFunction, *funny.Function:
+		return "<function>"
+	case map[string]funny.Value:
+		m := make(map[string]funny.Value, len(v))
+		for key, val := range v {
+			m[key] = normalize(val)
+		}
+		return m
+	case funny.Scope:
+		return normalize(map[string]funny.Value(v))
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		ls := make([]interface{}, len(v))
+		for index, item := range v {
+			ls[index] = normalize(item)
+		}
+		return ls
+	}
+	return v
+}
+
+// errorMessage the full message of error with its position and call stack, empty if it is nil
+func errorMessage(err error) string {
+	if fre, ok := err.(*funny.FunnyRuntimeError); ok {
+		return fre.Error() + fre.StackTrace()
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// stubTransport answers every request with the same json, so the scripts sending requests
+// run without the network
+type stubTransport struct{}
+
+func (stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"total": 1, "method": "` + req.Method + `"}`)),
+		Request:    req,
+	}, nil
+}
+
+// newFunny create an interpreter running by engine, whose output is kept in out
+func newFunny(engine funny.Engine, out *bytes.Buffer) *funny.Funny {
+	fn := funny.NewFunny(
+		funny.WithEngine(engine),
+		funny.WithHTTPClient(&http.Client{Transport: stubTransport{}}),
+	)
+	fn.Stdout = out
+	fn.Stdin = strings.NewReader("")
+	return fn
+}
+
+// assertSameResult run the programs by the tree-walking interpreter and by the vm one after
+// another, like a test case run after the setup of its file, then compare the errors, the
+// results, the output and the global variables
+func assertSameResult(t *testing.T, name string, programs ...*funny.Block) {
+	expectedOut, actualOut := new(bytes.Buffer), new(bytes.Buffer)
+	walker := newFunny(nil, expectedOut)
+	fn := newFunny(Engine{}, actualOut)
+	for _, program := range programs {
+		expected, expectedErr := walker.Run(&funny.Program{Statements: program})
+		actual, actualErr := fn.Run(&funny.Program{Statements: program})
+		if errors.Is(actualErr, ErrUndefined) {
+			// nothing runs when the program has undefined names, while the interpreter only
+			// fails if it reaches them, so the program is compared without checking them
+			actual, actualErr = runUnchecked(fn, program)
+		}
+		assert.Equal(t, errorMessage(expectedErr), errorMessage(actualErr), name)
+		assert.Equal(t, normalize(expected), normalize(actual), name)
+		if expectedErr != nil {
+			break
+		}
+	}
+	assert.Equal(t, expectedOut.String(), actualOut.String(), name)
+	assert.Equal(t, normalize(walker.Vars[0]), normalize(fn.Vars[0]), name)
+}
+
+func runUnchecked(fn *funny.Funny, program *funny.Block) (funny.Value, error) {
+	code, err := Compile(program, nil)
+	if err != nil {
+		return nil, err
+	}
+	return New(fn).Run(code)
+}
+
+// assertSameScript run script like assertSameResult, the scripts can not be parsed are not
+// compared since neither runs them
+func assertSameScript(t *testing.T, script string) {
+	block, err := funny.NewParser([]byte(script), "").Parse()
+	if err != nil {
+		return
+	}
+	assertSameResult(t, script, block)
+}
+
+func TestDifferential(t *testing.T) {
+	files, err := filepath.Glob("../*_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scripts []string
+	for _, file := range files {
+		scripts = append(scripts, testScripts(t, file)...)
+	}
+	assert.Greater(t, len(scripts), 100)
+	for _, script := range scripts {
+		assertSameScript(t, script)
+	}
+}
+
+// TestDifferentialSuites run the funny test files like funny test does, every test after
+// the setup statements of its file
+func TestDifferentialSuites(t *testing.T) {
+	files, err := funny.FindTestFiles("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := funny.NewParser(data, file).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSameResult(t, file, block)
+		setup, tests := funny.ParseTests(block)
+		for _, test := range tests {
+			assertSameResult(t, file+": "+test.Name, setup, test.Body)
+		}
+	}
+}
+
+// TestDifferentialMethodFields run the methods reading the fields added to their dicts after
+// they are created, the names are not undefined since the interpreter binds every field
+func TestDifferentialMethodFields(t *testing.T) {
+	scripts := []string{
+		`
+d = {
+  x = 1
+  get() {
+    return y
+  }
+}
+d.y = 2
+echoln(d.get())
+`,
+		`
+mk() {
+  return {
+    get() {
+      return name
+    }
+  }
+}
+o = mk()
+o.name = 'n'
+echoln(o.get())
+`,
+		`
+get() {
+  return x + 1
+}
+d = { x = 1 }
+d.get = get
+echoln(d.get())
+`,
+	}
+	for _, script := range scripts {
+		out := new(bytes.Buffer)
+		_, err := newFunny(Engine{}, out).Run(script)
+		assert.Nil(t, err, script)
+		assertSameScript(t, script)
+	}
+}
+
+// TestDifferentialAssertions run the assertions failing in the vm, which report the source of
+// their calls like the interpreter
+func TestDifferentialAssertions(t *testing.T) {
+	scripts := []string{
+		"x = 1\nassertEq(x + 1, 3)\n",
+		"f(x) {\n  assertNe(x, 1, 'not one')\n}\nf(1)\n",
+		"d = {\n  check(v) {\n    assertType(v, 'string')\n  }\n}\nd.check(1)\n",
+	}
+	for _, script := range scripts {
+		assertSameScript(t, script)
+	}
+	_, err := newFunny(Engine{}, new(bytes.Buffer)).Run(scripts[0])
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "assertEq(x + 1, 3) failed")
+	}
+}
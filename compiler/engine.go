@@ -0,0 +1,43 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jerloo/funny"
+)
+
+// Engine runs the programs of funny interpreters by the vm, the interpreters created with
+// funny.WithEngine(Engine{}) compile the code of Run and RunFile first. The names read but
+// never defined are reported with ErrUndefined before running, and the programs the compiler
+// fails on are left to the interpreter
+type Engine struct{}
+
+// Run compile program and run it by a new vm of fn
+func (Engine) Run(fn *funny.Funny, program *funny.Block) (funny.Value, bool) {
+	vm := New(fn)
+	code, err := vm.compileProgram(program)
+	if err != nil {
+		panic(err)
+	}
+	if code == nil {
+		return nil, false
+	}
+	r, _ := vm.execute(code, nil)
+	return r, true
+}
+
+// compileProgram compile program like Compile, the code is nil if the compiler does not
+// support it. Other failures of the compiler are returned as errors
+func (vm *VM) compileProgram(program *funny.Block) (code *Code, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok && errors.Is(e, errNotDeclared) {
+				code, err = nil, nil
+				return
+			}
+			code, err = nil, fmt.Errorf("compiler failed: %v", r)
+		}
+	}()
+	return vm.Compile(program)
+}
@@ -0,0 +1,128 @@
+package compiler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jerloo/funny"
+	"github.com/stretchr/testify/assert"
+)
+
+// newEngineFunny create an interpreter running the programs by the vm
+func newEngineFunny() *funny.Funny {
+	return funny.NewFunny(funny.WithEngine(Engine{}))
+}
+
+func TestEngineRun(t *testing.T) {
+	fn := newEngineFunny()
+	r, err := fn.Run(`
+add(a, b) {
+  return a + b
+}
+return add(1, 2)
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r)
+	// the functions are compiled by the vm
+	_, ok := fn.Lookup("add").(*Closure)
+	assert.True(t, ok)
+
+	file := filepath.Join(t.TempDir(), "main.funny")
+	assert.Nil(t, os.WriteFile(file, []byte("sub(a, b) {\n  return a - b\n}\nreturn sub(add(5, 2), 4)\n"), 0644))
+	r, err = fn.RunFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, r)
+	_, ok = fn.Lookup("sub").(*Closure)
+	assert.True(t, ok)
+
+	// the interpreter evaluates the statements itself without engine, importing the vm does
+	// not change it
+	walker := funny.NewFunny()
+	_, err = walker.Run("add(a, b) {\n  return a + b\n}")
+	assert.Nil(t, err)
+	_, ok = walker.Lookup("add").(*funny.Closure)
+	assert.True(t, ok)
+}
+
+func TestEngineTests(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "math_test.funny")
+	assert.Nil(t, os.WriteFile(file, []byte(`
+double(x) {
+  return x * 2
+}
+
+test('doubles') {
+  assertEq(double(2), 4)
+}
+
+test('fails') {
+  assertEq(double(2), 5)
+}
+`), 0644))
+	results, err := (&funny.TestRunner{New: newEngineFunny}).RunFile(context.Background(), file)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, funny.TestPassed, results[0].Status)
+		assert.Equal(t, funny.TestFailed, results[1].Status)
+		assert.Equal(t, 10, results[1].ErrorPosition().Line)
+	}
+}
+
+func TestEngineUndefined(t *testing.T) {
+	out := new(bytes.Buffer)
+	fn := newEngineFunny()
+	fn.Stdout = out
+	fn.Stdin = strings.NewReader("")
+	_, err := fn.Run("echoln('before')\nundefinedFn()\n")
+	assert.True(t, errors.Is(err, ErrUndefined))
+	assert.Equal(t, "function [undefinedFn] not defined", err.(*funny.FunnyRuntimeError).Msg)
+	assert.Equal(t, 1, err.(*funny.FunnyRuntimeError).Postion.Line)
+	// nothing runs
+	assert.Equal(t, "", out.String())
+
+	// nil is read as an undefined variable
+	r, err := fn.Run("return readline() == nil")
+	assert.Nil(t, err)
+	assert.Equal(t, true, r)
+
+	file := filepath.Join(t.TempDir(), "undefined_test.funny")
+	assert.Nil(t, os.WriteFile(file, []byte("test('typo') {\n  assertEq(lenght('ab'), 2)\n}\n"), 0644))
+	results, err := (&funny.TestRunner{New: newEngineFunny}).RunFile(context.Background(), file)
+	assert.Nil(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, funny.TestFailed, results[0].Status)
+		assert.True(t, errors.Is(results[0].Err, ErrUndefined))
+		assert.Equal(t, "assertEq(lenght('ab'), 2)", results[0].Source)
+	}
+}
+
+func TestEngineCompilerFailure(t *testing.T) {
+	// a broken program makes the compiler panic, which is reported instead of left to the
+	// interpreter
+	broken := &funny.Block{
+		Statements: []funny.Statement{
+			&funny.FunctionCall{Name: "echo", Parameters: []funny.Statement{nil}},
+		},
+	}
+	_, err := newEngineFunny().Run(&funny.Program{Statements: broken})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "compiler failed")
+	}
+
+	// assigning the variables the compiler does not find declared is left to the interpreter
+	c := newCompiler("f", nil, funny.Position{}, &resolver{})
+	c.scope = newScope(nil, nil, nil)
+	func() {
+		defer func() {
+			err := recover().(*funny.FunnyRuntimeError)
+			assert.Equal(t, "variable [x] is not declared", err.Msg)
+			assert.True(t, errors.Is(err, errNotDeclared))
+		}()
+		c.store("x", funny.Position{})
+	}()
+}
@@ -0,0 +1,158 @@
+package compiler
+
+import "fmt"
+
+// Opcode operation of one instruction
+type Opcode byte
+
+// Operations of the vm, A and B are the operands of the instruction
+const (
+	// OpConst push constant A
+	OpConst Opcode = iota
+	// OpLoad push variable of binding A
+	OpLoad
+	// OpStore pop and assign to global variable named name A
+	OpStore
+	// OpLoadLocal push local variable in slot A, or the variable of binding B if it is not assigned yet
+	OpLoadLocal
+	// OpStoreLocal pop and assign to local variable in slot A, or to the variable of binding B
+	// in the enclosing functions if it is not assigned yet but they have it
+	OpStoreLocal
+	// OpPop discard the top value
+	OpPop
+
+	// OpAdd and other binary operators pop right and left, then push the result
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpGt
+	OpGte
+	OpLt
+	OpLte
+	OpEq
+	OpNotEq
+	OpIn
+	OpNotIn
+	// OpNeg push negative of the top value
+	OpNeg
+	// OpNot push not of the top value which must be bool, A is the constant of operator name
+	OpNot
+	// OpBool check the top value is bool, A is the constant of operator name
+	OpBool
+
+	// OpJump jump to A
+	OpJump
+	// OpJumpIfFalse pop condition of if statement and jump to A if it is false
+	OpJumpIfFalse
+	// OpJumpIfFalseOrPop jump to A if the top value is false, pop it otherwise
+	OpJumpIfFalseOrPop
+	// OpJumpIfTrueOrPop jump to A if the top value is true, pop it otherwise
+	OpJumpIfTrueOrPop
+
+	// OpList pop A values and push them as a list
+	OpList
+	// OpDict pop A pairs of key and value and push them as a dict
+	OpDict
+	// OpTemplate pop A values and push them joined as a string
+	OpTemplate
+	// OpClosure push closure of function code constant A
+	OpClosure
+
+	// OpCall pop B params and call the function, A is the constant of *functionCall
+	OpCall
+	// OpCallMethod pop B params and the dict, then call its method, A is the constant of *methodCall
+	OpCallMethod
+	// OpReturn return the top value from current function, its position is current if A is 1
+	OpReturn
+
+	// OpField pop dict and push its field named name A
+	OpField
+	// OpFieldKey pop key and dict and push the field, A is the constant of *funny.Field
+	OpFieldKey
+	// OpAssignField pop key, dict and value, then push the dict with the field set,
+	// A is the constant of *funny.Field
+	OpAssignField
+	// OpIndex pop key and container and push the item, A is the constant of *funny.ListAccess
+	OpIndex
+	// OpSetIndex pop key, container and value, then set the item, A is the constant of *funny.ListAccess
+	OpSetIndex
+	// OpSlice pop end, start and container and push the part, A is the constant of *funny.Slice
+	OpSlice
+
+	// OpIter pop iterable and push its iterator
+	OpIter
+	// OpNext push item and index of the iterator on top, or pop the iterator and jump to A after the last one
+	OpNext
+
+	// OpSetupTry register handler at A for errors happened until OpPopTry,
+	// the handler starts with the error on top of the stack
+	OpSetupTry
+	// OpPopTry unregister the last handler
+	OpPopTry
+	// OpCatch replace the error on top with its dict
+	OpCatch
+	// OpEndFinally pop the pending error and raise it if it is not nil
+	OpEndFinally
+
+	// OpFail raise the error constant A
+	OpFail
+	// OpStep count one statement and check the limits and the context of the interpreter
+	OpStep
+)
+
+var opcodeNames = []string{
+	OpConst:            "CONST",
+	OpLoad:             "LOAD",
+	OpStore:            "STORE",
+	OpLoadLocal:        "LOAD_LOCAL",
+	OpStoreLocal:       "STORE_LOCAL",
+	OpPop:              "POP",
+	OpAdd:              "ADD",
+	OpSub:              "SUB",
+	OpMul:              "MUL",
+	OpDiv:              "DIV",
+	OpGt:               "GT",
+	OpGte:              "GTE",
+	OpLt:               "LT",
+	OpLte:              "LTE",
+	OpEq:               "EQ",
+	OpNotEq:            "NOTEQ",
+	OpIn:               "IN",
+	OpNotIn:            "NOTIN",
+	OpNeg:              "NEG",
+	OpNot:              "NOT",
+	OpBool:             "BOOL",
+	OpJump:             "JUMP",
+	OpJumpIfFalse:      "JUMP_IF_FALSE",
+	OpJumpIfFalseOrPop: "JUMP_IF_FALSE_OR_POP",
+	OpJumpIfTrueOrPop:  "JUMP_IF_TRUE_OR_POP",
+	OpList:             "LIST",
+	OpDict:             "DICT",
+	OpTemplate:         "TEMPLATE",
+	OpClosure:          "CLOSURE",
+	OpCall:             "CALL",
+	OpCallMethod:       "CALL_METHOD",
+	OpReturn:           "RETURN",
+	OpField:            "FIELD",
+	OpFieldKey:         "FIELD_KEY",
+	OpAssignField:      "ASSIGN_FIELD",
+	OpIndex:            "INDEX",
+	OpSetIndex:         "SET_INDEX",
+	OpSlice:            "SLICE",
+	OpIter:             "ITER",
+	OpNext:             "NEXT",
+	OpSetupTry:         "SETUP_TRY",
+	OpPopTry:           "POP_TRY",
+	OpCatch:            "CATCH",
+	OpEndFinally:       "END_FINALLY",
+	OpFail:             "FAIL",
+	OpStep:             "STEP",
+}
+
+func (op Opcode) String() string {
+	if int(op) < len(opcodeNames) {
+		return opcodeNames[op]
+	}
+	return fmt.Sprintf("OP(%d)", op)
+}
@@ -0,0 +1,205 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jerloo/funny"
+)
+
+// ErrUndefined the error of reading a name which is never defined, reported by Compile
+// before the code runs
+var ErrUndefined = errors.New("undefined name")
+
+// errNotDeclared the error of assigning a variable the compiler did not find declared in the
+// function, the programs failed with it are left to the interpreter
+var errNotDeclared = errors.New("variable not declared")
+
+// Binding where a variable read by code lives. The variable is searched from the innermost
+// function outward like funny.Lookup does, then in the global scopes
+type Binding struct {
+	Name string
+	// Slots the slot of the variable in the frame of the function Slots index levels out
+	// from the current one, -1 if it is not a local variable of that function
+	Slots []int
+	// Builtin whether the builtin function named Name is loaded if the variable is not defined
+	Builtin bool
+}
+
+func (b *Binding) String() string {
+	for depth, slot := range b.Slots {
+		if slot >= 0 {
+			return fmt.Sprintf("%s@%d:%d", b.Name, depth, slot)
+		}
+	}
+	return b.Name
+}
+
+// scope the local variables of a function being compiled, nil for the program
+// whose variables are global
+type scope struct {
+	parent *scope
+	slots  map[string]int
+}
+
+// resolver state shared by the compilers of a program and the functions in it
+type resolver struct {
+	// globals the variables assigned by the program
+	globals map[string]bool
+	// defined reports names given by the host, nil if undefined names are not checked
+	defined func(name string) bool
+	// err the first undefined name found
+	err error
+}
+
+// declare collect the variables assigned by the statements of block, not counting the
+// ones assigned in the functions defined in it
+func declare(block *funny.Block, add func(name string)) {
+	if block == nil {
+		return
+	}
+	for _, item := range block.Statements {
+		switch item := item.(type) {
+		case *funny.Assign:
+			switch target := item.Target.(type) {
+			case *funny.Variable:
+				add(target.Name)
+			case *funny.Field:
+				add(target.Variable.Name)
+			}
+		case *funny.Function:
+			add(item.Name)
+		case *funny.IFStatement:
+			for s := funny.Statement(item); s != nil; {
+				ifs, ok := s.(*funny.IFStatement)
+				if !ok {
+					break
+				}
+				declare(ifs.Body, add)
+				declare(ifs.Else, add)
+				s = ifs.ElseIf
+			}
+		case *funny.FORStatement:
+			add(item.CurrentIndex.Name)
+			if v, ok := item.CurrentItem.(*funny.Variable); ok {
+				add(v.Name)
+			}
+			declare(&item.Block, add)
+		case *funny.TryStatement:
+			declare(item.Body, add)
+			if item.Error != nil {
+				add(item.Error.Name)
+			}
+			declare(item.Catch, add)
+			declare(item.Finally, add)
+		case *funny.ImportFunctionCall:
+			declare(item.Block, add)
+		}
+	}
+}
+
+// newScope create the scope of function with parameters and the variables assigned in body
+func newScope(parent *scope, parameters []string, body *funny.Block) *scope {
+	s := &scope{
+		parent: parent,
+		slots:  make(map[string]int),
+	}
+	add := func(name string) {
+		if _, ok := s.slots[name]; !ok {
+			s.slots[name] = len(s.slots)
+		}
+	}
+	for _, name := range parameters {
+		add(name)
+	}
+	declare(body, add)
+	return s
+}
+
+// locals the names of the slots of s in order
+func (s *scope) locals() []string {
+	names := make([]string, len(s.slots))
+	for name, slot := range s.slots {
+		names[slot] = name
+	}
+	return names
+}
+
+// bind resolve the variable named name read in the current scope
+func (c *Compiler) bind(name string, builtin bool) int {
+	key := name
+	if builtin {
+		key += "()"
+	}
+	if index, ok := c.bindings[key]; ok {
+		return index
+	}
+	b := &Binding{
+		Name:    name,
+		Builtin: builtin,
+	}
+	for s, depth := c.scope, 0; s != nil; s, depth = s.parent, depth+1 {
+		slot, ok := s.slots[name]
+		if !ok {
+			slot = -1
+		}
+		b.Slots = append(b.Slots, slot)
+	}
+	// keep the levels only up to the outermost one it is local
+	for len(b.Slots) > 0 && b.Slots[len(b.Slots)-1] < 0 {
+		b.Slots = b.Slots[:len(b.Slots)-1]
+	}
+	c.code.Bindings = append(c.code.Bindings, b)
+	c.bindings[key] = len(c.code.Bindings) - 1
+	return c.bindings[key]
+}
+
+// check record the error with message format if name read by the program is not defined by
+// it or the host. The names read in functions are not checked, any function called as the
+// method of a dict sees its fields, which may be added after the dict is created. nil is
+// not reported, the scripts read it as an undefined variable to get nil
+func (c *Compiler) check(format, name string, pos funny.Position) {
+	r := c.resolver
+	if r.defined == nil || r.err != nil || c.scope != nil || r.globals[name] || name == "nil" {
+		return
+	}
+	if !r.defined(name) {
+		r.err = &funny.FunnyRuntimeError{
+			Postion: pos,
+			Msg:     fmt.Sprintf(format, name),
+			Err:     ErrUndefined,
+		}
+	}
+}
+
+// load emit the instruction pushes the variable named name
+func (c *Compiler) load(name string, builtin bool, pos funny.Position) {
+	c.check("variable [%s] not defined", name, pos)
+	b := c.bind(name, builtin)
+	if c.scope != nil {
+		if slot, ok := c.scope.slots[name]; ok {
+			c.emit(OpLoadLocal, slot, b, pos)
+			return
+		}
+	}
+	c.emit(OpLoad, b, 0, pos)
+}
+
+// store emit the instruction pops and assigns to the variable named name, it is a global
+// variable in the program, and in functions it is the local one unless a function enclosing
+// it has the variable assigned already
+func (c *Compiler) store(name string, pos funny.Position) {
+	if c.scope == nil {
+		c.emit(OpStore, c.name(name), 0, pos)
+		return
+	}
+	slot, ok := c.scope.slots[name]
+	if !ok {
+		panic(&funny.FunnyRuntimeError{
+			Postion: pos,
+			Msg:     fmt.Sprintf("variable [%s] is not declared", name),
+			Err:     errNotDeclared,
+		})
+	}
+	c.emit(OpStoreLocal, slot, c.bind(name, false), pos)
+}
@@ -0,0 +1,134 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/jerloo/funny"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveUndefined(t *testing.T) {
+	cases := []struct {
+		code string
+		msg  string
+		line int
+	}{
+		{
+			code: "a = 1\necho(b)\n",
+			msg:  "variable [b] not defined",
+			line: 1,
+		},
+		{
+			code: "a = 1\nnotDefined(a)\n",
+			msg:  "function [notDefined] not defined",
+			line: 1,
+		},
+		{
+			code: "f() {\n  return x\n}\nx = f() + y\n",
+			msg:  "variable [y] not defined",
+			line: 3,
+		},
+	}
+	for _, c := range cases {
+		fn := funny.NewFunny()
+		_, err := New(fn).RunSource([]byte(c.code), "")
+		fre, ok := err.(*funny.FunnyRuntimeError)
+		if assert.True(t, ok, c.code) {
+			assert.Equal(t, c.msg, fre.Msg, c.code)
+			assert.Equal(t, c.line, fre.Postion.Line, c.code)
+		}
+		// nothing runs when the code has undefined names
+		assert.Equal(t, 0, len(fn.Vars[0]), c.code)
+	}
+}
+
+func TestResolveDefined(t *testing.T) {
+	fn := funny.NewFunnyWithScope(funny.Scope{
+		"host": 1,
+	})
+	r, err := New(fn).RunSource([]byte(`
+f() {
+  return later + host
+}
+later = 2
+obj = {
+  base = 10
+  add(n) {
+    return base + n + this.base
+  }
+}
+return [f(), obj.add(1), len([1])]
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{3, 21, 1}, r)
+
+	// the names read in functions may be fields of the dicts they are called on, they are
+	// looked up when the function runs
+	r, err = New(funny.NewFunny()).RunSource([]byte(`
+f() {
+  return missing
+}
+return f()
+`), "")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+}
+
+func TestResolveSlots(t *testing.T) {
+	r, err := New(funny.NewFunny()).RunSource([]byte(`
+x = 1
+outer(a) {
+  b = a + 1
+  inner(c) {
+    return a + b + c
+  }
+  return inner
+}
+shadow() {
+  x = x + 10
+  return x
+}
+add = outer(1)
+return [add(3), shadow(), x]
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{6, 11, 1}, r)
+
+	block, err := funny.NewParser([]byte(`
+f(a) {
+  b = a
+  g() {
+    return b
+  }
+  return g
+}
+`), "").Parse()
+	if err != nil {
+		panic(err)
+	}
+	code, err := Compile(block, nil)
+	assert.Nil(t, err)
+	f := code.Constants[0].(*Code)
+	assert.Equal(t, []string{"a", "b", "g"}, f.Locals)
+	g := f.Constants[0].(*Code)
+	assert.Equal(t, OpLoad, g.Instructions[1].Op)
+	assert.Equal(t, []int{-1, 1}, g.Bindings[g.Instructions[1].A].Slots)
+}
+
+func TestResolveThisShadowsOuter(t *testing.T) {
+	r, err := New(funny.NewFunny()).RunSource([]byte(`
+make() {
+  name = 'outer'
+  return {
+    name = 'field'
+    get() {
+      return name
+    }
+  }
+}
+obj = make()
+return obj.get()
+`), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "field", r)
+}
@@ -0,0 +1,584 @@
+package compiler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jerloo/funny"
+)
+
+// VM runs compiled code with the variables, builtins and call stack of a funny interpreter,
+// so the results are the same as funny.Run. Global variables are kept in Funny.Vars, while
+// the local variables of functions live in the slots of their frames
+type VM struct {
+	Funny *funny.Funny
+
+	// stack the operands of all running frames, each frame uses the values above its base
+	stack []funny.Value
+}
+
+// New create a vm running code with fn
+func New(fn *funny.Funny) *VM {
+	return &VM{
+		Funny: fn,
+	}
+}
+
+// Closure compiled function with the scopes where it was defined
+type Closure struct {
+	Code   *Code
+	Scopes []funny.Scope
+
+	env *env
+	vm  *VM
+}
+
+// Arity count of parameters
+func (c *Closure) Arity() int {
+	return len(c.Code.Parameters)
+}
+
+// Call run the function with params
+func (c *Closure) Call(fn *funny.Funny, params []funny.Value, this map[string]funny.Value) (funny.Value, bool) {
+	return c.vm.call(c, params, this)
+}
+
+func (c *Closure) String() string {
+	return c.Code.signature()
+}
+
+// MarshalJSON closure only dumps its signature, the captured scopes may contain itself
+func (c *Closure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// env the local variables of one call of a function, and the ones of the functions enclosing it
+type env struct {
+	values []funny.Value
+	// this the scope of this and its fields if the function is called as method
+	this   funny.Scope
+	parent *env
+}
+
+// unsetValue the type of unset
+type unsetValue struct{}
+
+// unset the value of local variables not assigned yet, reading them looks up the outer ones
+var unset funny.Value = &unsetValue{}
+
+// iterator the state of for statement on the stack
+type iterator func() (index, item funny.Value, ok bool)
+
+// handler where to go when an error happened in try
+type handler struct {
+	target int
+	depth  int
+	vars   []funny.Scope
+	calls  int
+}
+
+// frame the state of running code
+type frame struct {
+	vm       *VM
+	env      *env
+	code     *Code
+	pc       int
+	base     int
+	handlers []handler
+}
+
+func (f *frame) push(v funny.Value) {
+	f.vm.stack = append(f.vm.stack, v)
+}
+
+func (f *frame) pop() funny.Value {
+	stack := f.vm.stack
+	v := stack[len(stack)-1]
+	stack[len(stack)-1] = nil
+	f.vm.stack = stack[:len(stack)-1]
+	return v
+}
+
+func (f *frame) top() funny.Value {
+	return f.vm.stack[len(f.vm.stack)-1]
+}
+
+// popN pop n values in the order they were pushed, nil if n is 0
+func (f *frame) popN(n int) []funny.Value {
+	if n == 0 {
+		return nil
+	}
+	values := make([]funny.Value, n)
+	stack := f.vm.stack
+	copy(values, stack[len(stack)-n:])
+	f.vm.stack = stack[:len(stack)-n]
+	return values
+}
+
+// Run the code, any error happened is returned as *funny.FunnyRuntimeError
+func (vm *VM) Run(code *Code) (funny.Value, error) {
+	return vm.RunContext(vm.Funny.Context(), code)
+}
+
+// RunContext run the code under ctx like funny.RunContext
+func (vm *VM) RunContext(ctx context.Context, code *Code) (result funny.Value, err error) {
+	fn := vm.Funny
+	defer fn.Begin(ctx)()
+	vars, stack, depth := fn.Vars, len(fn.Stack), len(vm.stack)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fn.RuntimeError(r)
+			fn.Vars = vars
+			fn.Stack = fn.Stack[:stack]
+			vm.stack = vm.stack[:depth]
+		}
+	}()
+	result, _ = vm.execute(code, nil)
+	return result, nil
+}
+
+// Call call the function named name like funny.Call
+func (vm *VM) Call(name string, args ...interface{}) (funny.Value, error) {
+	return vm.CallContext(vm.Funny.Context(), name, args...)
+}
+
+// CallContext call the function named name under ctx like funny.CallContext, the operand
+// stack is also restored if the call fails
+func (vm *VM) CallContext(ctx context.Context, name string, args ...interface{}) (funny.Value, error) {
+	depth := len(vm.stack)
+	result, err := vm.Funny.CallContext(ctx, name, args...)
+	if err != nil {
+		vm.stack = vm.stack[:depth]
+	}
+	return result, err
+}
+
+// Compile compile block, the names can be read are the ones assigned by it, the variables
+// of the interpreter and the builtin functions
+func (vm *VM) Compile(block *funny.Block) (*Code, error) {
+	return Compile(block, vm.defined)
+}
+
+// defined whether name is a builtin function or a variable of the interpreter
+func (vm *VM) defined(name string) bool {
+	fn := vm.Funny
+	if _, ok := fn.Builtins.Get(name); ok || fn.Builtins.IsNamespace(name) {
+		return true
+	}
+	for _, scope := range fn.Vars {
+		if _, ok := scope[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// RunSource parse, compile and run the funny code in data
+func (vm *VM) RunSource(data []byte, filename string) (funny.Value, error) {
+	block, err := vm.Funny.NewParser(data, filename).Parse()
+	if err != nil {
+		return nil, err
+	}
+	code, err := vm.Compile(block)
+	if err != nil {
+		return nil, err
+	}
+	return vm.Run(code)
+}
+
+func (vm *VM) call(c *Closure, params []funny.Value, this map[string]funny.Value) (funny.Value, bool) {
+	fn := vm.Funny
+	code := c.Code
+	if len(params) < len(code.Parameters) {
+		panic(funny.P(fmt.Sprintf("function %s required %d args but %d given", code.Name, len(code.Parameters), len(params)), code.Position))
+	}
+	e := &env{
+		values: make([]funny.Value, len(code.Locals)),
+		parent: c.env,
+	}
+	copy(e.values, params[:len(code.Parameters)])
+	for index := len(code.Parameters); index < len(e.values); index++ {
+		e.values[index] = unset
+	}
+	if this != nil {
+		e.this = funny.Scope{
+			"this": this,
+		}
+		for key, val := range this {
+			e.this[key] = val
+		}
+	}
+	vars := fn.Vars
+	fn.Vars = c.Scopes
+	r, has := vm.execute(code, e)
+	fn.Vars = vars
+	return r, has
+}
+
+func (vm *VM) execute(code *Code, e *env) (funny.Value, bool) {
+	f := &frame{
+		vm:   vm,
+		env:  e,
+		code: code,
+		base: len(vm.stack),
+	}
+	for {
+		if r, done := vm.resume(f); done {
+			vm.stack = vm.stack[:f.base]
+			return r, true
+		}
+	}
+}
+
+// resume run the frame until it returns, done is false if an error was caught by a handler
+func (vm *VM) resume(f *frame) (result funny.Value, done bool) {
+	defer func() {
+		if len(f.handlers) == 0 {
+			return
+		}
+		if e := recover(); e != nil {
+			vm.handle(f, e)
+		}
+	}()
+	fn := vm.Funny
+	code := f.code
+	for {
+		in := code.Instructions[f.pc]
+		f.pc++
+		switch in.Op {
+		case OpConst:
+			f.push(code.Constants[in.A])
+		case OpLoad:
+			f.push(vm.lookup(f.env, code.Bindings[in.A]))
+		case OpStore:
+			fn.Assign(code.Names[in.A], f.pop())
+		case OpLoadLocal:
+			val := f.env.values[in.A]
+			if val == unset {
+				val = vm.lookup(f.env, code.Bindings[in.B])
+			}
+			f.push(val)
+		case OpStoreLocal:
+			vm.store(f.env, int(in.A), code.Bindings[in.B], f.pop())
+		case OpPop:
+			f.pop()
+		case OpAdd, OpSub, OpMul, OpDiv, OpGt, OpGte, OpLt, OpLte, OpEq, OpNotEq, OpIn, OpNotIn:
+			right := f.pop()
+			left := f.pop()
+			fn.Current = code.Positions[f.pc-1]
+			f.push(vm.binary(in.Op, left, right))
+		case OpNeg:
+			fn.Current = code.Positions[f.pc-1]
+			f.push(fn.EvalMinus(funny.Value(0), f.pop()))
+		case OpNot:
+			f.push(!vm.boolean(f.pop(), code.Constants[in.A], code.Positions[f.pc-1]))
+		case OpBool:
+			vm.boolean(f.top(), code.Constants[in.A], code.Positions[f.pc-1])
+		case OpJump:
+			f.pc = int(in.A)
+		case OpJumpIfFalse:
+			condition, ok := f.pop().(bool)
+			if !ok {
+				panic(funny.P("if statement condition must be boolen value", code.Positions[f.pc-1]))
+			}
+			if !condition {
+				f.pc = int(in.A)
+			}
+		case OpJumpIfFalseOrPop:
+			if !f.top().(bool) {
+				f.pc = int(in.A)
+			} else {
+				f.pop()
+			}
+		case OpJumpIfTrueOrPop:
+			if f.top().(bool) {
+				f.pc = int(in.A)
+			} else {
+				f.pop()
+			}
+		case OpList:
+			var ls []interface{}
+			for _, v := range f.popN(int(in.A)) {
+				ls = append(ls, v)
+			}
+			f.push(ls)
+		case OpDict:
+			values := f.popN(int(in.A) * 2)
+			dict := make(map[string]funny.Value, in.A)
+			for index := 0; index < len(values); index += 2 {
+				dict[values[index].(string)] = values[index+1]
+			}
+			f.push(dict)
+		case OpTemplate:
+			var b strings.Builder
+			for _, v := range f.popN(int(in.A)) {
+				b.WriteString(funny.Str(fn, []funny.Value{v}).(string))
+			}
+			f.push(b.String())
+		case OpClosure:
+			f.push(&Closure{
+				Code:   code.Constants[in.A].(*Code),
+				Scopes: fn.Vars[:len(fn.Vars):len(fn.Vars)],
+				env:    f.env,
+				vm:     vm,
+			})
+		case OpCall:
+			call := code.Constants[in.A].(*functionCall)
+			b := code.Bindings[call.Binding]
+			params := f.popN(int(in.B))
+			pos := code.Positions[f.pc-1]
+			fn.Current, fn.CurrentCall = pos, call.Call
+			function, this := vm.lookupFunction(f, b)
+			r, _ := fn.Invoke(b.Name, pos, function, params, this)
+			f.push(r)
+		case OpCallMethod:
+			f.push(vm.callMethod(f, code.Constants[in.A].(*methodCall), int(in.B), code.Positions[f.pc-1]))
+		case OpReturn:
+			if in.A == 1 {
+				fn.Current = code.Positions[f.pc-1]
+			}
+			return f.pop(), true
+		case OpField:
+			f.push(field(f.pop(), code.Names[in.A]))
+		case OpFieldKey:
+			key := f.pop()
+			root := f.pop()
+			name, ok := key.(string)
+			if !ok {
+				panic(funny.P(fmt.Sprintf("unknow type field access key %v", key), code.Positions[f.pc-1]))
+			}
+			f.push(field(root, name))
+		case OpAssignField:
+			key := f.pop()
+			root := f.pop()
+			f.push(assignField(code.Constants[in.A].(*funny.Field), root, key, f.pop()))
+		case OpIndex:
+			key := f.pop()
+			container := f.pop()
+			f.push(fn.GetIndex(code.Constants[in.A].(*funny.ListAccess), container, key))
+		case OpSetIndex:
+			key := f.pop()
+			container := f.pop()
+			fn.SetIndex(code.Constants[in.A].(*funny.ListAccess), container, key, f.pop())
+		case OpSlice:
+			end := f.pop()
+			start := f.pop()
+			container := f.pop()
+			f.push(fn.GetSlice(code.Constants[in.A].(*funny.Slice), container, start, end))
+		case OpIter:
+			fn.Current = code.Positions[f.pc-1]
+			f.push(iterator(fn.Iterator(f.pop())))
+		case OpNext:
+			index, item, ok := f.top().(iterator)()
+			if !ok {
+				f.pop()
+				f.pc = int(in.A)
+				continue
+			}
+			f.push(item)
+			f.push(index)
+		case OpSetupTry:
+			f.handlers = append(f.handlers, handler{
+				target: int(in.A),
+				depth:  len(vm.stack),
+				vars:   fn.Vars,
+				calls:  len(fn.Stack),
+			})
+		case OpPopTry:
+			f.handlers = f.handlers[:len(f.handlers)-1]
+		case OpCatch:
+			f.push(f.pop().(*funny.FunnyRuntimeError).Dict())
+		case OpEndFinally:
+			if fre, ok := f.pop().(*funny.FunnyRuntimeError); ok {
+				panic(fre)
+			}
+		case OpFail:
+			panic(funny.P(code.Constants[in.A].(string), code.Positions[f.pc-1]))
+		case OpStep:
+			fn.Current = code.Positions[f.pc-1]
+			fn.Step(fn.Current)
+		default:
+			panic(funny.P(fmt.Sprintf("unknow instruction %s", in.Op), code.Positions[f.pc-1]))
+		}
+	}
+}
+
+// handle restore the state saved by the last handler and jump to it with the error on the stack
+func (vm *VM) handle(f *frame, e interface{}) {
+	fn := vm.Funny
+	h := f.handlers[len(f.handlers)-1]
+	f.handlers = f.handlers[:len(f.handlers)-1]
+	fre := fn.RuntimeError(e)
+	fn.Vars = h.vars
+	fn.Stack = fn.Stack[:h.calls]
+	vm.stack = append(vm.stack[:h.depth], fre)
+	f.pc = h.target
+}
+
+func (vm *VM) binary(op Opcode, left, right funny.Value) funny.Value {
+	fn := vm.Funny
+	switch op {
+	case OpAdd:
+		return fn.EvalPlus(left, right)
+	case OpSub:
+		return fn.EvalMinus(left, right)
+	case OpMul:
+		return fn.EvalTimes(left, right)
+	case OpDiv:
+		return fn.EvalDevide(left, right)
+	case OpGt:
+		return fn.EvalGt(left, right)
+	case OpGte:
+		return fn.EvalGte(left, right)
+	case OpLt:
+		return fn.EvalLt(left, right)
+	case OpLte:
+		return fn.EvalLte(left, right)
+	case OpEq:
+		return fn.EvalEqual(left, right)
+	case OpNotEq:
+		return !fn.EvalEqual(left, right).(bool)
+	case OpIn:
+		return fn.EvalIn(left, right)
+	default:
+		return !fn.EvalIn(left, right).(bool)
+	}
+}
+
+// boolean check v is a bool value as operand of operator
+func (vm *VM) boolean(v, operator funny.Value, pos funny.Position) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	panic(funny.P(fmt.Sprintf("operator [%s] only support boolen value given [%s]", operator, funny.Typing(v)), pos))
+}
+
+// lookup find the variable of binding b from the function of e outward like funny.Lookup,
+// then in the global scopes
+func (vm *VM) lookup(e *env, b *Binding) funny.Value {
+	for depth := 0; e != nil; depth, e = depth+1, e.parent {
+		if depth < len(b.Slots) && b.Slots[depth] >= 0 {
+			if val := e.values[b.Slots[depth]]; val != unset {
+				return val
+			}
+		}
+		if e.this != nil {
+			if val, ok := e.this[b.Name]; ok {
+				return val
+			}
+		}
+	}
+	val := vm.Funny.Lookup(b.Name)
+	if val == nil && b.Builtin {
+		if builtin, ok := vm.Funny.Builtins.Get(b.Name); ok {
+			return builtin
+		}
+	}
+	return val
+}
+
+// store assign val to the local variable in slot of e like funny.Assign, if it is not assigned
+// yet, the first of the fields of this and the variables of the enclosing functions having it
+// is assigned instead
+func (vm *VM) store(e *env, slot int, b *Binding, val funny.Value) {
+	if e.values[slot] == unset {
+		for depth, outer := 0, e; outer != nil; depth, outer = depth+1, outer.parent {
+			if depth > 0 && depth < len(b.Slots) && b.Slots[depth] >= 0 && outer.values[b.Slots[depth]] != unset {
+				outer.values[b.Slots[depth]] = val
+				return
+			}
+			if _, ok := outer.this[b.Name]; ok {
+				outer.this[b.Name] = val
+				return
+			}
+		}
+	}
+	e.values[slot] = val
+}
+
+// lookupFunction find the function of binding b like funny.LookupFunction, the dict it belongs
+// to is returned as this when it is a method
+func (vm *VM) lookupFunction(f *frame, b *Binding) (funny.Value, map[string]funny.Value) {
+	fn := vm.Funny
+	var look funny.Value
+	this, _ := vm.lookup(f.env, f.code.Bindings[f.code.This]).(map[string]funny.Value)
+	if this != nil {
+		look = this[b.Name]
+	}
+	if look == nil {
+		this = nil
+		look = vm.lookup(f.env, b)
+	}
+	if !funny.IsCallable(look) {
+		// a variable shadows the builtin only if it is a function
+		if builtin, ok := fn.Builtins.Get(b.Name); ok {
+			return builtin, nil
+		}
+	}
+	if look == nil {
+		panic(funny.P(fmt.Sprintf("function [%s] not defined", b.Name), fn.Current))
+	}
+	return look, this
+}
+
+// callMethod call method of the dict under the params, or the function of its binding if the
+// dict does not have it, or the namespaced builtin if the dict is not defined
+func (vm *VM) callMethod(f *frame, m *methodCall, count int, pos funny.Position) funny.Value {
+	fn := vm.Funny
+	b := f.code.Bindings[m.Binding]
+	name := b.Name
+	params := f.popN(count)
+	root := f.pop()
+	fn.Current = pos
+	this, ok := root.(map[string]funny.Value)
+	// the errors raised by the method are at the call like the interpreter does
+	call := m.Call.Position
+	if !ok {
+		qualified := m.Namespace + "." + name
+		if builtin, ok := fn.Builtins.Get(qualified); ok && root == nil {
+			fn.Current, fn.CurrentCall = call, m.Call
+			r, _ := fn.Invoke(qualified, call, builtin, params, nil)
+			return r
+		}
+		panic(funny.P(fmt.Sprintf("method [%s] only support dict but [%s] given", name, funny.Typing(root)), pos))
+	}
+	fn.Current, fn.CurrentCall = call, m.Call
+	method, ok := this[name]
+	if !ok {
+		method, this = vm.lookupFunction(f, b)
+	}
+	r, _ := fn.Invoke(name, call, method, params, this)
+	return r
+}
+
+// field get field named name of dict, nil if root is not a dict
+func field(root funny.Value, name string) funny.Value {
+	switch v := root.(type) {
+	case map[string]funny.Value:
+		return v[name]
+	case map[string]interface{}:
+		return v[name]
+	}
+	return nil
+}
+
+// assignField set the field of root dict to val like funny.AssignField, a new dict is created
+// if root is nil
+func assignField(field *funny.Field, root, key, val funny.Value) funny.Value {
+	dict := make(map[string]funny.Value)
+	if root != nil {
+		d, ok := root.(map[string]funny.Value)
+		if !ok {
+			panic(funny.P(fmt.Sprintf("assign field only support [dict] given [%s]", funny.Typing(root)), field.Position))
+		}
+		dict = d
+	}
+	name, ok := key.(string)
+	if !ok {
+		panic(funny.P(fmt.Sprintf("field key %s must be string", field.Value.(*funny.Variable).Name), field.Position))
+	}
+	dict[name] = val
+	return dict
+}
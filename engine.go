@@ -0,0 +1,17 @@
+package funny
+
+// Engine runs the programs of interpreters instead of evaluating their statements one by
+// one, like the bytecode vm of package compiler
+type Engine interface {
+	// Run run the statements of program by fn and return the result, errors are raised like
+	// the interpreter does. It is not ok if the engine can not run them, then fn evaluates
+	// them instead
+	Run(fn *Funny, program *Block) (result Value, ok bool)
+}
+
+// WithEngine run programs by engine, or evaluate their statements if engine is nil
+func WithEngine(engine Engine) Option {
+	return func(fn *Funny) {
+		fn.Engine = engine
+	}
+}
@@ -0,0 +1,30 @@
+package funny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// constEngine runs every program to the same result, or none of them if it is not ok
+type constEngine struct {
+	result Value
+	ok     bool
+}
+
+func (e constEngine) Run(fn *Funny, program *Block) (Value, bool) {
+	return e.result, e.ok
+}
+
+func TestEngine(t *testing.T) {
+	fn := NewFunny(WithEngine(constEngine{result: 42, ok: true}))
+	r, err := fn.Run("return 1")
+	assert.Nil(t, err)
+	assert.Equal(t, 42, r)
+
+	// the interpreter falls back to evaluating the programs the engine can not run
+	fn = NewFunny(WithEngine(constEngine{}))
+	r, err = fn.Run("return 1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r)
+}
@@ -1,14 +1,103 @@
 package funny
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
+
+// StackFrame one function call of the funny call stack
+type StackFrame struct {
+	Name     string
+	Position Position
+}
+
+func (sf StackFrame) String() string {
+	return fmt.Sprintf("at %s (%s:%d:%d)", sf.Name, sf.Position.File, sf.Position.Line+1, sf.Position.Col+1)
+}
+
+// MaxStackFrames the most frames kept in the stack of errors, the innermost and the outermost
+// halves are kept when the calls are deeper
+const MaxStackFrames = 20
 
 type FunnyRuntimeError struct {
 	Postion Position
 	Msg     string
+	// Stack the funny call stack when error happened, innermost call first
+	Stack []StackFrame
+	// Elided the number of frames left out of the middle of Stack when it is deeper than
+	// MaxStackFrames
+	Elided int
+	// Err the go error caused this error if any
+	Err error
+	// Value the value given to throw() if any
+	Value Value
 }
 
 func (fre *FunnyRuntimeError) Error() string {
-	return fmt.Sprintf("%s:%d:%d: %s\n", fre.Postion.File, fre.Postion.Line, fre.Postion.Col, fre.Msg)
+	return fmt.Sprintf("%s:%d:%d: %s\n", fre.Postion.File, fre.Postion.Line+1, fre.Postion.Col+1, fre.Msg)
+}
+
+// Unwrap return the go error caused this error
+func (fre *FunnyRuntimeError) Unwrap() error {
+	return fre.Err
+}
+
+// StackTrace format the call stack one frame per line
+func (fre *FunnyRuntimeError) StackTrace() string {
+	sb := new(strings.Builder)
+	for index, frame := range fre.Stack {
+		if fre.Elided > 0 && index == MaxStackFrames/2 {
+			fmt.Fprintf(sb, "  ... %d more frames\n", fre.Elided)
+		}
+		sb.WriteString("  ")
+		sb.WriteString(frame.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// stackFrames the frames of the call stack innermost first, the ones in the middle are left
+// out and counted when it is deeper than MaxStackFrames
+func stackFrames(stack []StackFrame) (frames []StackFrame, elided int) {
+	keep := func(index int) {
+		frames = append(frames, stack[index])
+	}
+	if len(stack) <= MaxStackFrames {
+		for index := len(stack) - 1; index >= 0; index-- {
+			keep(index)
+		}
+		return frames, 0
+	}
+	half := MaxStackFrames / 2
+	for index := len(stack) - 1; index >= len(stack)-half; index-- {
+		keep(index)
+	}
+	for index := half - 1; index >= 0; index-- {
+		keep(index)
+	}
+	return frames, len(stack) - MaxStackFrames
+}
+
+// Dict convert the error into a funny dict for catch
+func (fre *FunnyRuntimeError) Dict() map[string]Value {
+	stack := make([]interface{}, 0, len(fre.Stack))
+	for _, frame := range fre.Stack {
+		stack = append(stack, map[string]Value{
+			"name": frame.Name,
+			"file": frame.Position.File,
+			"line": frame.Position.Line + 1,
+			"col":  frame.Position.Col + 1,
+		})
+	}
+	return map[string]Value{
+		"message": fre.Msg,
+		"file":    fre.Postion.File,
+		"line":    fre.Postion.Line + 1,
+		"col":     fre.Postion.Col + 1,
+		"stack":   stack,
+		"elided":  fre.Elided,
+		"value":   fre.Value,
+	}
 }
 
 // P panic
@@ -1,9 +1,16 @@
 package funny
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path"
+	"sort"
+	"strings"
 )
 
 // Value one value of some like variable
@@ -13,32 +20,110 @@ type Value interface {
 // Scope stores variables
 type Scope map[string]Value
 
-// Funny the virtual machine of funny code
+// Closure a function value with the scopes where it was defined
+type Closure struct {
+	Function *Function
+	Scopes   []Scope
+}
+
+// MarshalJSON closure only dumps its signature, the captured scopes may contain itself
+func (c *Closure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *Closure) String() string {
+	return c.Function.SignatureString()
+}
+
+// Arity count of parameters
+func (c *Closure) Arity() int {
+	return len(c.Function.Parameters)
+}
+
+// Call eval closure with params
+func (c *Closure) Call(fn *Funny, params []Value, this map[string]Value) (Value, bool) {
+	return fn.EvalClosure(c, params, this)
+}
+
+// Callable function values can be called by funny, like closures or functions compiled to other forms
+type Callable interface {
+	// Arity count of parameters
+	Arity() int
+	// Call with params, this is bound in the function body if not nil
+	Call(fn *Funny, params []Value, this map[string]Value) (Value, bool)
+}
+
+// Funny the virtual machine of funny code. One interpreter must not be used on several
+// goroutines at the same time, but interpreters are independent of each other and the
+// parsed programs can be shared by them
 type Funny struct {
-	Vars      []Scope
-	Functions map[string]BuiltinFunction
+	Vars     []Scope
+	Builtins *Registry
 
 	Current Position
+	// CurrentCall the function call run by the interpreter at Current, which builtins can
+	// use to describe the code calling them
+	CurrentCall *FunctionCall
+	Stack       []StackFrame
+
+	Limits Limits
+	state  runState
+
+	// Capabilities what the builtins are allowed to do, unrestricted if it is nil
+	Capabilities *Capabilities
+
+	// Stdout, Stderr and Stdin the streams used by builtins like echo and readline,
+	// they are the ones of the process by default
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+	// stdin the buffered Stdin read by readline, stdinFrom the Stdin it buffers
+	stdin     *bufio.Reader
+	stdinFrom io.Reader
+
+	// HTTPClient the client http builtins send requests by, http.DefaultClient if it is nil
+	HTTPClient *http.Client
+
+	// Engine runs the programs if it is not nil
+	Engine Engine
 }
 
 // NewFunnyWithScope create a new funny
-func NewFunnyWithScope(vars Scope) *Funny {
-	return &Funny{
+func NewFunnyWithScope(vars Scope, options ...Option) *Funny {
+	fn := &Funny{
 		Vars: []Scope{
 			vars,
 		},
-		Functions: FUNCTIONS,
+		Builtins: NewRegistry(),
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Stdin:    os.Stdin,
+	}
+	for _, option := range options {
+		option(fn)
 	}
+	return fn
 }
 
 // Create a new funny with default settings
-func NewFunny() *Funny {
-	return &Funny{
-		Vars: []Scope{
-			make(map[string]Value),
-		},
-		Functions: FUNCTIONS,
+func NewFunny(options ...Option) *Funny {
+	return NewFunnyWithScope(make(map[string]Value), options...)
+}
+
+// ReadLine read one line from Stdin without the line ending, ok is false at the end of input
+func (i *Funny) ReadLine() (line string, ok bool) {
+	if i.stdin == nil || i.stdinFrom != i.Stdin {
+		i.stdin = bufio.NewReader(i.Stdin)
+		i.stdinFrom = i.Stdin
+	}
+	line, err := i.stdin.ReadString('\n')
+	if err != nil && err != io.EOF {
+		panic(P(err.Error(), i.Current))
 	}
+	if err == io.EOF && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
 }
 
 // Debug get debug value
@@ -53,61 +138,118 @@ func (i *Funny) Debug() bool {
 	return false
 }
 
-func (i *Funny) RunFile(filename string) (Value, bool) {
+// RunFile run a funny script file
+func (i *Funny) RunFile(filename string) (Value, error) {
+	return i.RunFileContext(i.Context(), filename)
+}
+
+// RunFileContext run a funny script file under ctx, see RunContext
+func (i *Funny) RunFileContext(ctx context.Context, filename string) (Value, error) {
 	if !path.IsAbs(filename) {
 		currentDir, err := os.Getwd()
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		filename = path.Join(currentDir, filename)
 	}
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	parser := NewParser(data, filename)
-	parser.ContentFile = filename
-	statements, err := parser.Parse()
+	statements, err := i.NewParser(data, filename).Parse()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	program := Program{
 		Statements: statements,
 	}
-	return i.Run(program)
+	return i.RunContext(ctx, program)
+}
+
+// NewParser create a parser of the code in file filename, whose imports are limited by the
+// capabilities of the interpreter
+func (i *Funny) NewParser(data []byte, filename string) *Parser {
+	parser := NewParser(data, filename)
+	parser.Capabilities = i.Capabilities
+	return parser
+}
+
+// Run the part of the code, any error happened is returned as *FunnyRuntimeError
+func (i *Funny) Run(v interface{}) (Value, error) {
+	return i.RunContext(i.Context(), v)
 }
 
-// Run the part of the code
-func (i *Funny) Run(v interface{}) (Value, bool) {
+// RunContext run the part of the code like Run, it stops with error at the next statement
+// once ctx is done, and the builtins doing I/O are given ctx
+func (i *Funny) RunContext(ctx context.Context, v interface{}) (result Value, err error) {
+	defer i.Begin(ctx)()
+	vars, stack := i.Vars, len(i.Stack)
 	defer func() {
-		if err := recover(); err != nil {
-			fmt.Println(err)
+		if r := recover(); r != nil {
+			err = i.RuntimeError(r)
+			i.Vars = vars
+			i.Stack = i.Stack[:stack]
 		}
 	}()
+	result, _ = i.run(v)
+	return result, nil
+}
+
+func (i *Funny) run(v interface{}) (Value, bool) {
 	switch v := v.(type) {
 	case Statement:
 		return i.EvalStatement(v)
 	case Program:
-		return i.Run(&v)
+		return i.run(&v)
 	case *Program:
-		return i.EvalBlock(v.Statements)
+		if i.Engine != nil && v.Statements != nil {
+			if r, ok := i.Engine.Run(i, v.Statements); ok {
+				return r, true
+			}
+		}
+		r, has := i.EvalBlock(v.Statements)
+		i.checkLoopControl(r, has)
+		return r, has
 	case string:
-		return i.Run([]byte(v))
+		return i.run([]byte(v))
 	case []byte:
-		parser := NewParser(v, "")
-		statements, err := parser.Parse()
+		statements, err := i.NewParser(v, "").Parse()
 		if err != nil {
 			panic(err)
 		}
 		program := Program{
 			Statements: statements,
 		}
-		return i.Run(program)
+		return i.run(program)
 	default:
 		panic(P(fmt.Sprintf("unknow type of running value: [%v]", v), i.Current))
 	}
 }
 
+// RuntimeError convert a recovered value into *FunnyRuntimeError with current call stack
+func (i *Funny) RuntimeError(r interface{}) *FunnyRuntimeError {
+	var fre *FunnyRuntimeError
+	switch v := r.(type) {
+	case *FunnyRuntimeError:
+		fre = v
+	case error:
+		fre = &FunnyRuntimeError{
+			Postion: i.Current,
+			Msg:     v.Error(),
+			Err:     v,
+		}
+	default:
+		fre = &FunnyRuntimeError{
+			Postion: i.Current,
+			Msg:     fmt.Sprint(v),
+		}
+	}
+	if fre.Stack == nil {
+		fre.Stack, fre.Elided = stackFrames(i.Stack)
+	}
+	return fre
+}
+
 // EvalBlock eval a block
 func (i *Funny) EvalBlock(block *Block) (Value, bool) {
 	if block == nil {
@@ -123,13 +265,10 @@ func (i *Funny) EvalBlock(block *Block) (Value, bool) {
 	return Value(nil), false
 }
 
-// RegisterFunction register a builtin or customer function
+// RegisterFunction register a builtin or customer function, only for this interpreter.
+// The name can be namespaced like db.query
 func (i *Funny) RegisterFunction(name string, fn BuiltinFunction) error {
-	if _, exists := i.Functions[name]; exists {
-		return fmt.Errorf("function [%s] already exists", name)
-	}
-	i.Functions[name] = fn
-	return nil
+	return i.Builtins.Register(name, fn)
 }
 
 // EvalIfStatement eval if statement
@@ -162,12 +301,160 @@ func (i *Funny) EvalIfStatement(item *IFStatement) (Value, bool) {
 // EvalForStatement eval for statement
 func (i *Funny) EvalForStatement(item *FORStatement) (Value, bool) {
 	i.Current = item.GetPosition()
-	panic(P("NOT IMPLEMENT", i.Current))
+	iterable := i.EvalExpression(item.Iterable.Value)
+	itemName := item.CurrentItem.(*Variable).Name
+	var result Value
+	var has bool
+	i.Iterate(iterable, func(index, val Value) bool {
+		i.Assign(item.CurrentIndex.Name, index)
+		i.Assign(itemName, val)
+		r, h := i.EvalBlock(&item.Block)
+		if h {
+			switch r.(type) {
+			case *Break:
+				return false
+			case *Continue:
+				return true
+			}
+			result, has = r, h
+			return false
+		}
+		return true
+	})
+	return result, has
+}
+
+// Iterate call fn with every index and item of a list, dict or string until fn returns false,
+// dict keys are visited in sorted order
+func (i *Funny) Iterate(iterable Value, fn func(index, item Value) bool) {
+	next := i.Iterator(iterable)
+	for {
+		index, item, ok := next()
+		if !ok || !fn(index, item) {
+			return
+		}
+	}
+}
+
+// Iterator get a function returns every index and item of a list, dict or string in turn,
+// ok is false after the last one. Dict keys are visited in sorted order
+func (i *Funny) Iterator(iterable Value) func() (index, item Value, ok bool) {
+	pos := -1
+	switch v := iterable.(type) {
+	case []interface{}:
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(v) {
+				return pos, v[pos], true
+			}
+			return nil, nil, false
+		}
+	case []Value:
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(v) {
+				return pos, v[pos], true
+			}
+			return nil, nil, false
+		}
+	case []map[string]interface{}:
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(v) {
+				return pos, v[pos], true
+			}
+			return nil, nil, false
+		}
+	case map[string]Value:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(keys) {
+				return keys[pos], v[keys[pos]], true
+			}
+			return nil, nil, false
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(keys) {
+				return keys[pos], v[keys[pos]], true
+			}
+			return nil, nil, false
+		}
+	case string:
+		runes := []rune(v)
+		return func() (Value, Value, bool) {
+			if pos++; pos < len(runes) {
+				return pos, string(runes[pos]), true
+			}
+			return nil, nil, false
+		}
+	}
+	panic(P(fmt.Sprintf("for only support [list, dict, string] given [%s]", Typing(iterable)), i.Current))
+}
+
+// EvalTryStatement eval try statement, errors of body are bound to the catch variable as a dict
+func (i *Funny) EvalTryStatement(item *TryStatement) (Value, bool) {
+	i.Current = item.GetPosition()
+	r, has, fre := i.evalGuarded(item.Body)
+	if fre != nil && item.Catch != nil {
+		if item.Error != nil {
+			i.Assign(item.Error.Name, Value(fre.Dict()))
+		}
+		r, has, fre = i.evalGuarded(item.Catch)
+	}
+	if item.Finally != nil {
+		fr, fhas := i.EvalBlock(item.Finally)
+		if fhas {
+			return fr, fhas
+		}
+	}
+	if fre != nil {
+		panic(fre)
+	}
+	return r, has
+}
+
+// evalGuarded eval block and recover the error happened in it
+func (i *Funny) evalGuarded(block *Block) (r Value, has bool, fre *FunnyRuntimeError) {
+	vars, stack := i.Vars, len(i.Stack)
+	defer func() {
+		if e := recover(); e != nil {
+			fre = i.RuntimeError(e)
+			i.Vars = vars
+			i.Stack = i.Stack[:stack]
+		}
+	}()
+	r, has = i.EvalBlock(block)
+	return r, has, nil
+}
+
+// checkLoopControl panics if a break or continue escaped from its for statement
+func (i *Funny) checkLoopControl(r Value, has bool) {
+	if !has {
+		return
+	}
+	switch v := r.(type) {
+	case *Break:
+		panic(P("break outside of for statement", v.Position))
+	case *Continue:
+		panic(P("continue outside of for statement", v.Position))
+	}
 }
 
 // EvalStatement eval statement
 func (i *Funny) EvalStatement(item Statement) (Value, bool) {
 	i.Current = item.GetPosition()
+	switch item.(type) {
+	case *NewLine, *Comment:
+	default:
+		i.Step(i.Current)
+	}
 	switch item := item.(type) {
 	case *Assign:
 		switch a := item.Target.(type) {
@@ -175,6 +462,8 @@ func (i *Funny) EvalStatement(item Statement) (Value, bool) {
 			i.Assign(a.Name, i.EvalExpression(item.Value))
 		case *Field:
 			i.AssignField(a, i.EvalExpression(item.Value))
+		case *ListAccess:
+			i.AssignIndex(a, i.EvalExpression(item.Value))
 		default:
 			panic(P("invalid assignment", item.Position))
 		}
@@ -188,6 +477,11 @@ func (i *Funny) EvalStatement(item Statement) (Value, bool) {
 		if has {
 			return val, true
 		}
+	case *TryStatement:
+		val, has := i.EvalTryStatement(item)
+		if has {
+			return val, true
+		}
 	case *FunctionCall:
 		i.EvalFunctionCall(item)
 	case *ImportFunctionCall:
@@ -204,17 +498,23 @@ func (i *Funny) EvalStatement(item Statement) (Value, bool) {
 			case *Comment:
 				break
 			case *Function:
-				i.Assign(d.Name, d)
+				i.Assign(d.Name, i.NewClosure(d))
 			default:
 				panic(P("module must only contains assignment and func", item.Position))
 			}
 		}
 	case *Return:
 		return i.EvalExpression(item.Value), true
+	case *Break:
+		return Value(item), true
+	case *Continue:
+		return Value(item), true
 	case *Function:
-		i.Assign(item.Name, item)
+		i.Assign(item.Name, i.NewClosure(item))
 	case *Field:
 		i.EvalField(item)
+	case *ListAccess, *Slice:
+		i.EvalExpression(item)
 	case *NewLine:
 		break
 	case *Comment:
@@ -232,70 +532,243 @@ func (i *Funny) EvalFunctionCall(item *FunctionCall) (Value, bool) {
 	for _, p := range item.Parameters {
 		params = append(params, i.EvalExpression(p))
 	}
-	if fn, ok := i.Functions[item.Name]; ok {
-		return fn(i, params), true
-	}
-	this := i.LookupDefault("this", nil)
+	i.Current = item.GetPosition()
+	i.CurrentCall = item
+	fn, this := i.LookupFunction(item.Name)
+	return i.Invoke(item.Name, item.Position, fn, params, this)
+}
+
+// LookupFunction find the function named name in this, current scopes or builtins,
+// the dict it belongs to is returned as this when it is a method. Unlike the variables of
+// other types, functions defined by the script take precedence over the builtins of the
+// same name, so the scripts written before builtins like map or find were added keep working
+func (i *Funny) LookupFunction(name string) (Value, map[string]Value) {
 	var look Value
+	this, _ := i.LookupDefault("this", nil).(map[string]Value)
 	if this != nil {
-		look = this.(map[string]Value)[item.Name]
+		look = this[name]
 	}
 	if look == nil {
-		look := i.LookupDefault(item.Name, nil)
-		if look == nil {
-			panic(P(fmt.Sprintf("function [%s] not defined", item.Name), i.Current))
+		this = nil
+		look = i.LookupDefault(name, nil)
+	}
+	if !IsCallable(look) {
+		// a variable shadows the builtin only if it is a function
+		if fn, ok := i.Builtins.Get(name); ok {
+			return fn, nil
 		}
-		fun := i.Lookup(item.Name).(*Function)
-		return i.EvalFunction(*fun, params)
+	}
+	if look == nil {
+		panic(P(fmt.Sprintf("function [%s] not defined", name), i.Current))
+	}
+	return look, this
+}
 
-	} else {
-		fun := look.(*Function)
-		return i.EvalFunction(*fun, params)
+// IsCallable whether the value can be called as function
+func IsCallable(v Value) bool {
+	switch v.(type) {
+	case Callable, *Function, BuiltinFunction, func(*Funny, []Value) Value:
+		return true
+	}
+	return false
+}
+
+// CallFunction call a function value such as closure or builtin with params
+func (i *Funny) CallFunction(fn Value, params []Value) Value {
+	name := "fn"
+	if c, ok := fn.(*Closure); ok && c.Function.Name != "" {
+		name = c.Function.Name
+	}
+	r, _ := i.Invoke(name, i.Current, fn, params, nil)
+	return r
+}
+
+// Call call the function named name defined by the code run before, or a builtin function,
+// with args converted by ToValue. A method of a dict variable can be called like
+// handlers.onResponse, and the interpreter is left as it was if the call fails
+func (i *Funny) Call(name string, args ...interface{}) (Value, error) {
+	return i.CallContext(i.Context(), name, args...)
+}
+
+// CallContext call the function named name under ctx like Call
+func (i *Funny) CallContext(ctx context.Context, name string, args ...interface{}) (result Value, err error) {
+	defer i.Begin(ctx)()
+	vars, stack, current := i.Vars, len(i.Stack), i.Current
+	defer func() {
+		if r := recover(); r != nil {
+			err = i.RuntimeError(r)
+			i.Vars = vars
+			i.Stack = i.Stack[:stack]
+		}
+		i.Current = current
+	}()
+	params := make([]Value, len(args))
+	for index, arg := range args {
+		params[index] = ToValue(arg)
+	}
+	fn, this := i.lookupCallee(name)
+	result, _ = i.Invoke(name, i.Current, fn, params, this)
+	return result, nil
+}
+
+// lookupCallee find the function named name for Call, names with dot are builtins in
+// namespace or methods of dict variables
+func (i *Funny) lookupCallee(name string) (Value, map[string]Value) {
+	dot := strings.Index(name, ".")
+	if dot < 0 {
+		return i.LookupFunction(name)
+	}
+	if fn, ok := i.Builtins.Get(name); ok {
+		return fn, nil
+	}
+	if this, ok := i.Lookup(name[:dot]).(map[string]Value); ok {
+		if method := this[name[dot+1:]]; IsCallable(method) {
+			return method, this
+		}
+	}
+	panic(P(fmt.Sprintf("function [%s] not defined", name), i.Current))
+}
+
+// Functions the names of the functions defined by the code run before in sorted order,
+// not including builtin functions
+func (i *Funny) Functions() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, scope := range i.Vars {
+		for name, v := range scope {
+			if !seen[name] && IsCallable(v) {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Invoke call function value fn named name at pos, this is bound in the function body if not nil
+func (i *Funny) Invoke(name string, pos Position, fn Value, params []Value, this map[string]Value) (Value, bool) {
+	i.checkDepth(pos)
+	i.Stack = append(i.Stack, StackFrame{
+		Name:     name,
+		Position: pos,
+	})
+	var r Value
+	var has bool
+	switch fn := fn.(type) {
+	case BuiltinFunction:
+		r, has = fn(i, params), true
+	case func(*Funny, []Value) Value:
+		r, has = fn(i, params), true
+	case Callable:
+		r, has = fn.Call(i, params, this)
+	case *Function:
+		r, has = i.EvalFunction(*fn, params)
+	default:
+		panic(P(fmt.Sprintf("[%s] is not a function but [%s]", name, Typing(fn)), pos))
+	}
+	i.Stack = i.Stack[:len(i.Stack)-1]
+	return r, has
+}
+
+// NewClosure create a closure of function captures current scopes
+func (i *Funny) NewClosure(item *Function) *Closure {
+	return &Closure{
+		Function: item,
+		// full slice expression makes calls copy the scopes before appending
+		Scopes: i.Vars[:len(i.Vars):len(i.Vars)],
 	}
 }
 
+// EvalClosure eval closure in the scopes where it was defined, this and its fields are
+// visible in the function body when this is not nil
+func (i *Funny) EvalClosure(c *Closure, params []Value, this map[string]Value) (Value, bool) {
+	vars := i.Vars
+	i.Vars = c.Scopes
+	if this != nil {
+		scope := Scope{
+			"this": this,
+		}
+		for key, val := range this {
+			scope[key] = val
+		}
+		i.PushScope(scope)
+	}
+	r, has := i.EvalFunction(*c.Function, params)
+	i.Vars = vars
+	return r, has
+}
+
 // EvalFunction eval function
 func (i *Funny) EvalFunction(item Function, params []Value) (Value, bool) {
 	i.Current = item.GetPosition()
 	if len(params) < len(item.Parameters) {
 		panic(P(fmt.Sprintf("function %s required %d args but %d given", item.Name, len(item.Parameters), len(params)), item.Position))
 	}
+	// parameters are always local
 	scope := Scope{}
-	i.PushScope(scope)
 	for index, p := range item.Parameters {
-		i.Assign(p.(*Variable).Name, params[index])
+		scope[p.(*Variable).Name] = params[index]
 	}
+	i.PushScope(scope)
 	r, has := i.EvalBlock(item.Body)
 	i.PopScope()
+	i.checkLoopControl(r, has)
 	return r, has
 }
 
 // AssignField assign one field value
 func (i *Funny) AssignField(field *Field, val Value) {
 	i.Current = field.GetPosition()
-	scope := make(map[string]Value)
+	i.Assign(field.Variable.Name, i.setField(field, i.Lookup(field.Variable.Name), val))
+}
 
-	find := i.Lookup(field.Variable.Name)
-	if find != nil {
-		scope = find.(map[string]Value)
+// setField set the field of root dict to val and return the dict, a new dict is created if
+// root is nil, and so are the dicts of the nested fields like d.a.b
+func (i *Funny) setField(field *Field, root Value, val Value) Value {
+	scope := make(map[string]Value)
+	if root != nil {
+		dict, ok := root.(map[string]Value)
+		if !ok {
+			panic(P(fmt.Sprintf("assign field only support [dict] given [%s]", Typing(root)), field.Position))
+		}
+		scope = dict
+	}
+	switch v := field.Value.(type) {
+	case *StringExpression:
+		scope[v.Value] = val
+	case *Variable:
+		key, ok := i.Lookup(v.Name).(string)
+		if !ok {
+			panic(P(fmt.Sprintf("field key %s must be string", v.Name), field.Position))
+		}
+		scope[key] = val
+	case *Field:
+		scope[v.Variable.Name] = i.setField(v, scope[v.Variable.Name], val)
+	default:
+		panic(P(fmt.Sprintf("invalid field assignment [%s]", field.String()), field.Position))
 	}
-	scope[field.Value.(*Variable).Name] = val
-	i.Assign(field.Variable.Name, Value(scope))
+	return Value(scope)
 }
 
-// Assign assign one variable
+// Assign assign one variable, it is rebound where it is found first from the innermost scope
+// outward, so closures can change the variables of the functions enclosing them. The global
+// scope is not searched, a variable assigned in a function is local unless it encloses one
 func (i *Funny) Assign(name string, val Value) {
+	for index := len(i.Vars) - 1; index > 0; index-- {
+		if _, ok := i.Vars[index][name]; ok {
+			i.Vars[index][name] = val
+			return
+		}
+	}
 	i.Vars[len(i.Vars)-1][name] = val
 }
 
 // LookupDefault find one variable named name and get value, if not found, return default
 func (i *Funny) LookupDefault(name string, defaultVal Value) Value {
 	for index := len(i.Vars) - 1; index >= 0; index-- {
-		item := i.Vars[index]
-		for k, v := range item {
-			if k == name {
-				return v
-			}
+		if v, ok := i.Vars[index][name]; ok {
+			return v
 		}
 	}
 	return defaultVal
@@ -304,11 +777,8 @@ func (i *Funny) LookupDefault(name string, defaultVal Value) Value {
 // Lookup find one variable named name and get value
 func (i *Funny) Lookup(name string) Value {
 	for index := len(i.Vars) - 1; index >= 0; index-- {
-		item := i.Vars[index]
-		for k, v := range item {
-			if k == name {
-				return v
-			}
+		if v, ok := i.Vars[index][name]; ok {
+			return v
 		}
 	}
 	return Value(nil)
@@ -358,20 +828,28 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 			return Value((!i.EvalEqual(i.EvalExpression(item.Left), i.EvalExpression(item.Right)).(bool)))
 		case NAME:
 			switch item.Operator.Data {
-			case NOT:
-				switch v := item.Right.(type) {
-				case *BinaryExpression:
-					if v.Operator.Data == IN {
-						return Value(!i.EvalIn(i.EvalExpression(item.Left), v.Right).(bool))
-					}
+			case AND:
+				if !i.EvalBoolean(item.Left, AND) {
+					return Value(false)
+				}
+				return Value(i.EvalBoolean(item.Right, AND))
+			case OR:
+				if i.EvalBoolean(item.Left, OR) {
+					return Value(true)
 				}
-				return Value(!i.EvalExpression(item.Right).(bool))
+				return Value(i.EvalBoolean(item.Right, OR))
 			case IN:
-				return i.EvalIn(i.EvalExpression(item.Left), item.Right)
+				return i.EvalIn(i.EvalExpression(item.Left), i.EvalExpression(item.Right))
+			case NOTIN:
+				return Value(!i.EvalIn(i.EvalExpression(item.Left), i.EvalExpression(item.Right)).(bool))
 			}
-		default:
-			panic(P(fmt.Sprintf("only support [+] [-] [*] [/] [>] [>=] [==] [<=] [<] [in] [not] given [%s]", expression.(*BinaryExpression).Operator.Data), expression.GetPosition()))
 		}
+		panic(P(fmt.Sprintf("only support [+] [-] [*] [/] [>] [>=] [==] [!=] [<=] [<] [in] [not in] [and] [or] given [%s]", item.Operator.Data), item.GetPosition()))
+	case *UnaryExpression:
+		if item.Operator.Kind == MINUS {
+			return i.EvalMinus(Value(0), i.EvalExpression(item.Expression))
+		}
+		return Value(!i.EvalBoolean(item.Expression, item.Operator.Data))
 	case *List:
 		var ls []interface{}
 		for _, item := range item.Values {
@@ -394,7 +872,7 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 			case *Comment:
 				break
 			case *Function:
-				scope[d.Name] = d
+				scope[d.Name] = i.NewClosure(d)
 			default:
 				panic(P("dict struct must only contains assignment and func", item.Position))
 			}
@@ -403,7 +881,15 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 	case *Boolen:
 		return Value(item.Value)
 	case *Variable:
-		return i.Lookup(item.Name)
+		val := i.Lookup(item.Name)
+		if val == nil {
+			if fn, ok := i.Builtins.Get(item.Name); ok {
+				return Value(fn)
+			}
+		}
+		return val
+	case *Function:
+		return Value(i.NewClosure(item))
 	case *Literal:
 		return Value(item.Value)
 	case *FunctionCall:
@@ -412,10 +898,17 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 	case *Field:
 		return i.EvalField(item)
 	case *ListAccess:
-		ls := i.Lookup(item.List.Name)
-		lsEntry := ls.([]interface{})
-		val := lsEntry[item.Index]
-		return Value(val)
+		return i.EvalListAccess(item)
+	case *Slice:
+		return i.EvalSlice(item)
+	case *StringExpression:
+		return Value(item.Value)
+	case *StringTemplate:
+		var b strings.Builder
+		for _, part := range item.Parts {
+			b.WriteString(Str(i, []Value{i.EvalExpression(part)}).(string))
+		}
+		return Value(b.String())
 	case *ImportFunctionCall:
 		scope := make(map[string]Value)
 
@@ -432,7 +925,7 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 			case *Comment:
 				break
 			case *Function:
-				scope[d.Name] = d
+				scope[d.Name] = i.NewClosure(d)
 			default:
 				panic(P("module must only contains assignment and func", item.Position))
 			}
@@ -444,15 +937,38 @@ func (i *Funny) EvalExpression(expression Statement) Value {
 	panic(P(fmt.Sprintf("eval expression error: [%s]", expression.String()), expression.GetPosition()))
 }
 
-func (i *Funny) EvalIn(leftValue Value, right Statement) Value {
-	switch rightValue := right.(type) {
-	case *List:
-		for _, item := range rightValue.Values {
-			v := i.EvalExpression(item)
-			if leftValue == v {
+// EvalBoolean eval expression that must be a boolen value as operand of operator
+func (i *Funny) EvalBoolean(expression Statement, operator string) bool {
+	val := i.EvalExpression(expression)
+	if b, ok := val.(bool); ok {
+		return b
+	}
+	panic(P(fmt.Sprintf("operator [%s] only support boolen value given [%s]", operator, Typing(val)), expression.GetPosition()))
+}
+
+// EvalIn item in list, key in dict or substring in string
+func (i *Funny) EvalIn(leftValue, rightValue Value) Value {
+	switch v := rightValue.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if i.EvalEqual(leftValue, item).(bool) {
 				return Value(true)
 			}
 		}
+	case map[string]Value:
+		if key, ok := leftValue.(string); ok {
+			_, exists := v[key]
+			return Value(exists)
+		}
+	case map[string]interface{}:
+		if key, ok := leftValue.(string); ok {
+			_, exists := v[key]
+			return Value(exists)
+		}
+	case string:
+		if sub, ok := leftValue.(string); ok {
+			return Value(strings.Contains(v, sub))
+		}
 	}
 	return Value(false)
 }
@@ -463,17 +979,21 @@ func (i *Funny) EvalField(item *Field) Value {
 	root := i.Lookup(item.Variable.Name)
 	switch v := item.Value.(type) {
 	case *FunctionCall:
-		this := root.(map[string]Value)
-		scope := Scope{
-			"this": this,
+		this, ok := root.(map[string]Value)
+		if !ok {
+			name := item.Variable.Name + "." + v.Name
+			if builtin, ok := i.Builtins.Get(name); ok && root == nil {
+				// namespaced builtin like http.get
+				return i.invokeMethod(name, v, builtin, nil)
+			}
+			panic(P(fmt.Sprintf("method [%s] only support dict but [%s] given", v.Name, Typing(root)), item.Position))
 		}
-		for key, val := range this {
-			scope[key] = val
+		method, ok := this[v.Name]
+		if !ok {
+			r, _ := i.EvalFunctionCall(v)
+			return r
 		}
-		i.PushScope(scope)
-		r, _ := i.EvalFunctionCall(v)
-		i.PopScope()
-		return r
+		return i.invokeMethod(v.Name, v, method, this)
 	case *StringExpression:
 		if val, ok := root.(map[string]Value); ok {
 			return Value(val[v.Value])
@@ -519,17 +1039,227 @@ func (i *Funny) EvalField(item *Field) Value {
 	return Value(nil)
 }
 
+// invokeMethod call method named name with the params of item
+func (i *Funny) invokeMethod(name string, item *FunctionCall, method Value, this map[string]Value) Value {
+	var params []Value
+	for _, p := range item.Parameters {
+		params = append(params, i.EvalExpression(p))
+	}
+	i.Current = item.GetPosition()
+	i.CurrentCall = item
+	r, _ := i.Invoke(name, item.Position, method, params, this)
+	return r
+}
+
+// EvalListAccess get item of list or string by index, or value of dict by key
+func (i *Funny) EvalListAccess(item *ListAccess) Value {
+	container := i.EvalExpression(item.List)
+	key := i.EvalExpression(item.Index)
+	return i.GetIndex(item, container, key)
+}
+
+// GetIndex get item of container by key evaluated for item
+func (i *Funny) GetIndex(item *ListAccess, container, key Value) Value {
+	i.Current = item.GetPosition()
+	switch c := container.(type) {
+	case []interface{}:
+		return Value(c[i.listIndex(item, len(c), key)])
+	case string:
+		runes := []rune(c)
+		return Value(string(runes[i.listIndex(item, len(runes), key)]))
+	case map[string]Value:
+		return c[i.dictKey(item, key)]
+	case map[string]interface{}:
+		return Value(c[i.dictKey(item, key)])
+	}
+	panic(P(fmt.Sprintf("index [%s] only support [list, dict, string] given [%s]", item.String(), Typing(container)), item.Position))
+}
+
+// AssignIndex assign item of list by index or value of dict by key
+func (i *Funny) AssignIndex(item *ListAccess, val Value) {
+	container := i.EvalExpression(item.List)
+	key := i.EvalExpression(item.Index)
+	i.SetIndex(item, container, key, val)
+}
+
+// SetIndex set item of container by key evaluated for item
+func (i *Funny) SetIndex(item *ListAccess, container, key, val Value) {
+	i.Current = item.GetPosition()
+	switch c := container.(type) {
+	case []interface{}:
+		c[i.listIndex(item, len(c), key)] = val
+	case map[string]Value:
+		c[i.dictKey(item, key)] = val
+	case map[string]interface{}:
+		c[i.dictKey(item, key)] = val
+	default:
+		panic(P(fmt.Sprintf("index assignment [%s] only support [list, dict] given [%s]", item.String(), Typing(container)), item.Position))
+	}
+}
+
+// listIndex check index is in range of length, negative index counts from the end
+func (i *Funny) listIndex(item *ListAccess, length int, index Value) int {
+	n, ok := index.(int)
+	if !ok {
+		panic(P(fmt.Sprintf("list index [%s] must be [int] given [%s]", item.Index.String(), Typing(index)), item.Position))
+	}
+	if n < 0 {
+		n += length
+	}
+	if n < 0 || n >= length {
+		panic(P(fmt.Sprintf("list index %d out of range with length %d", index, length), item.Position))
+	}
+	return n
+}
+
+// dictKey check key of dict is string
+func (i *Funny) dictKey(item *ListAccess, key Value) string {
+	k, ok := key.(string)
+	if !ok {
+		panic(P(fmt.Sprintf("dict key [%s] must be [string] given [%s]", item.Index.String(), Typing(key)), item.Position))
+	}
+	return k
+}
+
+// EvalSlice get part of list or string, the result list is a copy
+func (i *Funny) EvalSlice(item *Slice) Value {
+	container := i.EvalExpression(item.List)
+	var start, end Value
+	if item.Start != nil {
+		start = i.EvalExpression(item.Start)
+	}
+	if item.End != nil {
+		end = i.EvalExpression(item.End)
+	}
+	return i.GetSlice(item, container, start, end)
+}
+
+// GetSlice get part of container by bounds evaluated for item, bounds missing in item are ignored
+func (i *Funny) GetSlice(item *Slice, container, start, end Value) Value {
+	i.Current = item.GetPosition()
+	switch c := container.(type) {
+	case []interface{}:
+		from, to := i.sliceRange(item, len(c), start, end)
+		result := make([]interface{}, to-from)
+		copy(result, c[from:to])
+		return Value(result)
+	case string:
+		runes := []rune(c)
+		from, to := i.sliceRange(item, len(runes), start, end)
+		return Value(string(runes[from:to]))
+	}
+	panic(P(fmt.Sprintf("slice [%s] only support [list, string] given [%s]", item.String(), Typing(container)), item.Position))
+}
+
+// sliceRange check bounds of slice, negative bound counts from the end and out of range bound is clamped
+func (i *Funny) sliceRange(item *Slice, length int, start, end Value) (int, int) {
+	from, to := 0, length
+	if item.Start != nil {
+		from = i.sliceBound(item, item.Start, start, length)
+	}
+	if item.End != nil {
+		to = i.sliceBound(item, item.End, end, length)
+	}
+	if from > to {
+		from = to
+	}
+	return from, to
+}
+
+func (i *Funny) sliceBound(item *Slice, bound Statement, v Value, length int) int {
+	n, ok := v.(int)
+	if !ok {
+		panic(P(fmt.Sprintf("slice bound [%s] must be [int] given [%s]", bound.String(), Typing(v)), item.Position))
+	}
+	if n < 0 {
+		n += length
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > length {
+		return length
+	}
+	return n
+}
+
+// Promote convert two numbers into ints, or into float64s if any of them is not an int
+func Promote(left, right Value) (Value, Value, bool) {
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, nil, false
+	}
+	if li, ok := l.(int); ok {
+		if ri, ok := r.(int); ok {
+			return Value(li), Value(ri), true
+		}
+	}
+	return Value(toFloat(l)), Value(toFloat(r)), true
+}
+
+// toNumber normalize numeric value into int or float64
+func toNumber(v Value) (Value, bool) {
+	switch v := v.(type) {
+	case int:
+		return Value(v), true
+	case int32:
+		return Value(int(v)), true
+	case int64:
+		return Value(int(v)), true
+	case float32:
+		return Value(float64(v)), true
+	case float64:
+		return Value(v), true
+	}
+	return nil, false
+}
+
+func toFloat(v Value) float64 {
+	if i, ok := v.(int); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+// Compare compare two numbers, return -1, 0 or 1 and whether they are comparable
+func (i *Funny) Compare(left, right Value) (int, bool) {
+	l, r, ok := Promote(left, right)
+	if !ok {
+		return 0, false
+	}
+	switch l := l.(type) {
+	case int:
+		r := r.(int)
+		if l < r {
+			return -1, true
+		} else if l > r {
+			return 1, true
+		}
+	case float64:
+		r := r.(float64)
+		if l < r {
+			return -1, true
+		} else if l > r {
+			return 1, true
+		}
+	}
+	return 0, true
+}
+
 // EvalPlus +
 func (i *Funny) EvalPlus(left, right Value) Value {
+	if l, r, ok := Promote(left, right); ok {
+		if l, ok := l.(int); ok {
+			return Value(l + r.(int))
+		}
+		return Value(l.(float64) + r.(float64))
+	}
 	switch left := left.(type) {
 	case string:
 		if right, ok := right.(string); ok {
 			return Value(left + right)
 		}
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left + right)
-		}
 	case *[]Value:
 		if right, ok := right.(*[]Value); ok {
 			s := make([]Value, 0, len(*left)+len(*right))
@@ -571,16 +1301,18 @@ func (i *Funny) EvalPlus(left, right Value) Value {
 		}
 		return s
 	}
-	panic(P(fmt.Sprintf("eval plus only support types: [int, list, dict] given [%s]", Typing(left)), i.Current))
+	panic(P(fmt.Sprintf("eval plus only support types: [int, float, string, list, dict] given [%s]", Typing(left)), i.Current))
 }
 
 // EvalMinus -
 func (i *Funny) EvalMinus(left, right Value) Value {
-	switch left := left.(type) {
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left - right)
+	if l, r, ok := Promote(left, right); ok {
+		if l, ok := l.(int); ok {
+			return Value(l - r.(int))
 		}
+		return Value(l.(float64) - r.(float64))
+	}
+	switch left := left.(type) {
 	case *[]Value:
 		var s []Value
 		if right, ok := right.(*Scope); ok {
@@ -606,76 +1338,51 @@ func (i *Funny) EvalMinus(left, right Value) Value {
 		}
 		return s
 	}
-	panic(P("eval plus only support types: [int, list, dict]", i.Current))
+	panic(P(fmt.Sprintf("eval minus only support types: [int, float, list, dict] given [%s]", Typing(left)), i.Current))
 }
 
 // EvalTimes *
 func (i *Funny) EvalTimes(left, right Value) Value {
-	if l, ok := left.(int); ok {
-		if r, o := right.(int); o {
-			return Value(l * r)
+	if l, r, ok := Promote(left, right); ok {
+		if l, ok := l.(int); ok {
+			return Value(l * r.(int))
 		}
+		return Value(l.(float64) * r.(float64))
 	}
-	panic(P("eval plus times only support types: [int]", i.Current))
+	panic(P(fmt.Sprintf("eval times only support types: [int, float] given [%s] [%s]", Typing(left), Typing(right)), i.Current))
 }
 
 // EvalDevide /
 func (i *Funny) EvalDevide(left, right Value) Value {
-	if l, o := left.(int); o {
-		if r, k := right.(int); k {
-			return Value(l / r)
+	if l, r, ok := Promote(left, right); ok {
+		if c, _ := i.Compare(r, 0); c == 0 {
+			panic(P("eval devide by zero", i.Current))
 		}
+		if l, ok := l.(int); ok {
+			return Value(l / r.(int))
+		}
+		return Value(l.(float64) / r.(float64))
 	}
-	panic(P("eval plus devide only support types: [int]", i.Current))
+	panic(P(fmt.Sprintf("eval devide only support types: [int, float] given [%s] [%s]", Typing(left), Typing(right)), i.Current))
 }
 
 // EvalEqual ==
 func (i *Funny) EvalEqual(left, right Value) Value {
+	if c, ok := i.Compare(left, right); ok {
+		return Value(c == 0)
+	}
 	switch l := left.(type) {
 	case nil:
 		return Value(right == nil)
-	case int:
-		if r, ok := right.(int); ok {
-			return Value(l == r)
-		}
-		if r, ok := right.(float64); ok {
-			return Value(float64(l) == r)
-		}
-	case float64:
-		if r, ok := right.(float64); ok {
+	case bool:
+		if r, ok := right.(bool); ok {
 			return Value(l == r)
 		}
-		if r, ok := right.(int); ok {
-			return Value(l == float64(r))
-		}
-	case *[]Value:
-		if r, ok := right.(*[]Value); ok {
-			if len(*l) != len(*r) {
-				return Value(false)
-			}
-			for _, itemL := range *l {
-				for _, itemR := range *r {
-					if !i.EvalEqual(itemL, itemR).(bool) {
-						return Value(false)
-					}
-				}
-			}
-			return Value(true)
-		}
-	case *Scope:
-		if r, ok := right.(*Block); ok {
-			if len(*l) != len(r.Statements) {
-				return Value(false)
-			}
-			for _, itemL := range *l {
-				for _, itemR := range r.Statements {
-					if !i.EvalEqual(itemL, itemR).(bool) {
-						return Value(false)
-					}
-				}
-			}
-			return Value(true)
-		}
+		return Value(false)
+	case int, int64, float64:
+		return Value(false)
+	case []interface{}, map[string]Value, map[string]interface{}:
+		return Value(i.equalItems(left, right))
 	case string:
 		if r, ok := right.(string); ok {
 			return Value(l == r)
@@ -684,51 +1391,66 @@ func (i *Funny) EvalEqual(left, right Value) Value {
 	default:
 		panic(P(fmt.Sprintf("unsupport type [%s]", Typing(l)), i.Current))
 	}
-	return Value(false)
+}
+
+// equalItems whether the lists or dicts left and right have the equal items like ==
+func (i *Funny) equalItems(left, right Value) bool {
+	if l, ok := asDict(left); ok {
+		r, ok := asDict(right)
+		if !ok || len(l) != len(r) {
+			return false
+		}
+		for key, lv := range l {
+			rv, ok := r[key]
+			if !ok || !i.EvalEqual(lv, rv).(bool) {
+				return false
+			}
+		}
+		return true
+	}
+	l, _ := left.([]interface{})
+	r, ok := right.([]interface{})
+	if !ok || len(l) != len(r) {
+		return false
+	}
+	for index := range l {
+		if !i.EvalEqual(l[index], r[index]).(bool) {
+			return false
+		}
+	}
+	return true
 }
 
 // EvalGt >
 func (i *Funny) EvalGt(left, right Value) Value {
-	switch left := left.(type) {
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left > right)
-		}
+	if c, ok := i.Compare(left, right); ok {
+		return Value(c > 0)
 	}
-	panic(P("eval gt only support: [int]", i.Current))
+	panic(P("eval gt only support: [int, float]", i.Current))
 }
 
 // EvalGte >=
 func (i *Funny) EvalGte(left, right Value) Value {
-	switch left := left.(type) {
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left >= right)
-		}
+	if c, ok := i.Compare(left, right); ok {
+		return Value(c >= 0)
 	}
-	panic(P("eval lte only support: [int]", i.Current))
+	panic(P("eval gte only support: [int, float]", i.Current))
 }
 
 // EvalLt <
 func (i *Funny) EvalLt(left, right Value) Value {
-	switch left := left.(type) {
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left < right)
-		}
+	if c, ok := i.Compare(left, right); ok {
+		return Value(c < 0)
 	}
-	panic(P("eval lt only support: [int]", i.Current))
+	panic(P("eval lt only support: [int, float]", i.Current))
 }
 
 // EvalLte <=
 func (i *Funny) EvalLte(left, right Value) Value {
-	switch left := left.(type) {
-	case int:
-		if right, ok := right.(int); ok {
-			return Value(left <= right)
-		}
+	if c, ok := i.Compare(left, right); ok {
+		return Value(c <= 0)
 	}
-	panic(P("eval lte only support: [int]", i.Current))
+	panic(P("eval lte only support: [int, float]", i.Current))
 }
 
 // EvalDoubleEq ==
@@ -1,7 +1,9 @@
 package funny
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -250,6 +252,42 @@ c = m[b]
 	assert.Equal(t, 1, aInArray.(int))
 }
 
+func TestFunnyNestedFieldAssign(t *testing.T) {
+	data := `
+d = {
+  a = {
+    b = 1
+  }
+}
+e = d
+d.a.b = 5
+d.a.c = 6
+d.x.y = 7
+return [d, e.a.b]
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, []interface{}{
+		map[string]Value{
+			"a": map[string]Value{"b": 5, "c": 6},
+			"x": map[string]Value{"y": 7},
+		},
+		5,
+	}, r)
+
+	_, err := NewFunny().Run("d = {\n  a = 1\n}\nd.a.b = 5")
+	assert.Equal(t, "assign field only support [dict] given [int]", err.(*FunnyRuntimeError).Msg)
+}
+
+func TestBuiltinFunctionStrSplit(t *testing.T) {
+	data := `
+c = strsplit('a,b,,c', ',')
+`
+	i := NewFunny()
+	i.Run(data)
+	parts := i.Lookup("c")
+	assert.Equal(t, []interface{}{"a", "b", "", "c"}, parts)
+}
+
 func TestBuiltinFunctionRegexMatch(t *testing.T) {
 	data := `
 c = regexMatch('a', 'abcde')
@@ -305,3 +343,703 @@ a = (1 + 2) + 3
 	a := i.Lookup("a").(int)
 	assert.Equal(t, 6, a)
 }
+
+func TestFunnyForList(t *testing.T) {
+	data := `
+sum = 0
+indexes = 0
+for index, item in [1, 2, 3] {
+  sum = sum + item
+  indexes = indexes + index
+}
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, 6, i.Lookup("sum"))
+	assert.Equal(t, 3, i.Lookup("indexes"))
+}
+
+func TestFunnyForDict(t *testing.T) {
+	data := `
+m = {
+  b = 2
+  a = 1
+}
+keys = ''
+sum = 0
+for key, val in m {
+  keys = keys + key
+  sum = sum + val
+}
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, "ab", i.Lookup("keys"))
+	assert.Equal(t, 3, i.Lookup("sum"))
+}
+
+func TestFunnyForString(t *testing.T) {
+	data := `
+s = ''
+for index, ch in 'abc' {
+  s = ch + s
+}
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, "cba", i.Lookup("s"))
+}
+
+func TestFunnyForItems(t *testing.T) {
+	data := `
+items = [1, 2, 3]
+sum = 0
+for {
+  sum = sum + item
+}
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, 6, i.Lookup("sum"))
+}
+
+func TestFunnyForBreakContinue(t *testing.T) {
+	data := `
+sum = 0
+for index, item in [1, 2, 3, 4, 5] {
+  if item == 2 {
+    continue
+  }
+  if item == 4 {
+    break
+  }
+  sum = sum + item
+}
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, 4, i.Lookup("sum"))
+}
+
+func TestFunnyForReturn(t *testing.T) {
+	data := `
+find(xs, target) {
+  for index, item in xs {
+    if item == target {
+      return index
+    }
+  }
+  return 0 - 1
+}
+return find([5, 6, 7], 6)
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 1, r)
+}
+
+func TestFunnyExpressionPrecedence(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Value
+	}{
+		{"return 10 - 2 - 3", 5},
+		{"return 1 + 2 * 3", 7},
+		{"return (1 + 2) * 3", 9},
+		{"return 2 * 3 - 4 / 2", 4},
+		{"return 100 / 10 / 5", 2},
+		{"return 1 + 2 > 2", true},
+		{"return 1 + 1 in [2, 3]", true},
+		{"return 1 not in [2, 3]", true},
+	}
+	for _, item := range cases {
+		_, r := RunSingle(item.code)
+		assert.Equal(t, item.expected, r, item.code)
+	}
+}
+
+func TestFunnyDeepEqual(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Value
+	}{
+		{"return [1, 2] == [1, 2]", true},
+		{"return [1, 2] == [2, 1]", false},
+		{"return [1, 2] == [1, 2, 3]", false},
+		{"return [1, [2, 'a']] == [1.0, [2, 'a']]", true},
+		{"return [1, 2] != [1]", true},
+		{"return {a = 1} == {a = 1}", true},
+		{"return {a = 1} == {a = 2}", false},
+		{"return {a = 1} == {b = 1}", false},
+		{"return {a = [1]} == {a = [1]}", true},
+		{"return {a = 1} in [{a = 1}]", true},
+		{"return [1] in [[2], [1]]", true},
+		{"return [1] == 1", false},
+		{"return [] == {}", false},
+	}
+	for _, item := range cases {
+		_, r := RunSingle(item.code)
+		assert.Equal(t, item.expected, r, item.code)
+	}
+}
+
+func TestFunnyLogicalOperators(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Value
+	}{
+		{"return 1 == 1 and 2 > 1", true},
+		{"return 1 == 1 and 2 < 1", false},
+		{"return 1 == 2 or 2 > 1", true},
+		{"return 1 == 2 or 2 < 1", false},
+		{"return not 1 == 2", true},
+		{"return !true", false},
+		{"return !(1 > 2) and true", true},
+		{"return true == true", true},
+	}
+	for _, item := range cases {
+		_, r := RunSingle(item.code)
+		assert.Equal(t, item.expected, r, item.code)
+	}
+}
+
+func TestFunnyLogicalShortCircuit(t *testing.T) {
+	data := `
+a = false and notDefined()
+b = true or notDefined()
+`
+	i := NewFunny()
+	i.Run(data)
+	assert.Equal(t, false, i.Lookup("a"))
+	assert.Equal(t, true, i.Lookup("b"))
+}
+
+func TestFunnyFloat(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected Value
+	}{
+		{"return 1.5 + 1", 2.5},
+		{"return 1e3 - 1", 999.0},
+		{"return 2 * 0.25", 0.5},
+		{"return 3 / 2", 1},
+		{"return 3 / 2.0", 1.5},
+		{"return -1.5 + 1", -0.5},
+		{"return 10.5 > 10", true},
+		{"return 2 >= 2.0", true},
+		{"return 1 < 0.5", false},
+		{"return 2 == 2.0", true},
+		{"return int(2.9)", 2},
+		{"return int('42')", 42},
+		{"return float('1.25')", 1.25},
+		{"return float(2)", 2.0},
+		{"return str(1.5)", "1.5"},
+		{"return max(1, 2.5)", 2.5},
+		{"return min(1, 2.5)", 1},
+	}
+	for _, item := range cases {
+		_, r := RunSingle(item.code)
+		assert.Equal(t, item.expected, r, item.code)
+	}
+}
+
+func TestFunnyFloatFromJson(t *testing.T) {
+	i := NewFunny()
+	i.Assign("resp", map[string]interface{}{
+		"total": float64(12),
+	})
+	i.Run(`
+total = resp['total']
+more = total > 10
+`)
+	assert.Equal(t, true, i.Lookup("more"))
+}
+
+func TestFunnyRunError(t *testing.T) {
+	data := `
+f(x) {
+  return x / 0
+}
+g() {
+  return f(1)
+}
+g()
+`
+	i := NewFunny()
+	_, err := i.Run(data)
+	assert.NotNil(t, err)
+	fre, ok := err.(*FunnyRuntimeError)
+	assert.True(t, ok)
+	assert.Equal(t, "eval devide by zero", fre.Msg)
+	assert.Equal(t, 2, fre.Postion.Line)
+	assert.Equal(t, 2, len(fre.Stack))
+	assert.Equal(t, "f", fre.Stack[0].Name)
+	assert.Equal(t, "g", fre.Stack[1].Name)
+	assert.Equal(t, 1, len(i.Vars))
+	assert.Equal(t, 0, len(i.Stack))
+}
+
+func TestFunnyRunGoError(t *testing.T) {
+	i := NewFunny()
+	_, err := i.Run(`a = b64de('%')`)
+	assert.NotNil(t, err)
+	_, ok := err.(*FunnyRuntimeError)
+	assert.True(t, ok)
+}
+
+func TestFunnyRunFileNotFound(t *testing.T) {
+	i := NewFunny()
+	_, err := i.RunFile("not_exists.funny")
+	assert.NotNil(t, err)
+}
+
+func TestFunnyTryCatch(t *testing.T) {
+	data := `
+steps = ''
+try {
+  steps = steps + 'try '
+  b64de('%')
+  steps = steps + 'never '
+} catch err {
+  steps = steps + 'catch '
+  message = err.message
+  line = err.line
+} finally {
+  steps = steps + 'finally'
+}
+`
+	i := NewFunny()
+	_, err := i.Run(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "try catch finally", i.Lookup("steps"))
+	assert.Contains(t, i.Lookup("message"), "illegal base64")
+	assert.Equal(t, 5, i.Lookup("line"))
+}
+
+func TestFunnyThrow(t *testing.T) {
+	data := `
+check(code) {
+  if code != 0 {
+    throw({
+      message = 'bad code'
+      code = code
+    })
+  }
+  return true
+}
+try {
+  check(1)
+} catch err {
+  message = err.message
+  value = err.value
+  stack = err.stack
+}
+`
+	i := NewFunny()
+	_, err := i.Run(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "bad code", i.Lookup("message"))
+	assert.Equal(t, 1, i.Lookup("value").(map[string]Value)["code"])
+	stack := i.Lookup("stack").([]interface{})
+	assert.Equal(t, 2, len(stack))
+	assert.Equal(t, "throw", stack[0].(map[string]Value)["name"])
+	assert.Equal(t, "check", stack[1].(map[string]Value)["name"])
+}
+
+func TestFunnyTryFinallyRethrow(t *testing.T) {
+	data := `
+cleaned = false
+try {
+  throw('boom')
+} finally {
+  cleaned = true
+}
+`
+	i := NewFunny()
+	_, err := i.Run(data)
+	assert.NotNil(t, err)
+	assert.Equal(t, "boom", err.(*FunnyRuntimeError).Msg)
+	assert.Equal(t, true, i.Lookup("cleaned"))
+}
+
+func TestFunnyTryReturn(t *testing.T) {
+	data := `
+f() {
+  try {
+    return 1
+  } catch {
+    return 2
+  }
+}
+g() {
+  try {
+    throw('x')
+  } catch {
+    return 2
+  }
+}
+return f() + g()
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 3, r)
+}
+
+func TestFunnyFunctionValue(t *testing.T) {
+	data := `
+apply(f, x) {
+  return f(x)
+}
+double = fn(x) { return x * 2 }
+return apply(double, 20) + apply(fn(x) { return x + 1 }, 1)
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 42, r)
+}
+
+func TestFunnyClosure(t *testing.T) {
+	data := `
+adder(n) {
+  return fn(x) { return x + n }
+}
+counter() {
+  n = 0
+  return fn() {
+    n = n + 1
+    return n
+  }
+}
+add5 = adder(5)
+n = 100
+c = counter()
+c()
+other = counter()
+other()
+return [add5(10) + c(), other(), n]
+`
+	_, r := RunSingle(data)
+	// every counter has its own n, and the global one is not changed
+	assert.Equal(t, []interface{}{17, 2, 100}, r)
+}
+
+func TestFunnyFunctionInDict(t *testing.T) {
+	data := `
+ops = {
+  base = 10
+  mul = fn(a, b) { return a * b }
+  add(a) {
+    return base + a
+  }
+}
+return ops.mul(6, 7) + ops.add(1)
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 53, r)
+}
+
+func TestFunnyBuiltinAsValue(t *testing.T) {
+	data := `
+apply(f, x) {
+  return f(x)
+}
+return apply(str, 12)
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, "12", r)
+}
+
+func TestFunnyCallNotFunction(t *testing.T) {
+	i := NewFunny()
+	_, err := i.Run(`
+a = 1
+a()
+`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "[a] is not a function")
+}
+
+func TestFunnyMapFilterReduce(t *testing.T) {
+	data := `
+ls = [1, 2, 3, 4]
+doubled = map(ls, fn(x) { return x * 2 })
+even = filter(doubled, fn(x) { return x > 4 })
+return reduce(even, fn(acc, x) { return acc + x }, 0)
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 14, r)
+
+	_, r = RunSingle(`return map([1, 2], str)`)
+	assert.Equal(t, []interface{}{"1", "2"}, r)
+
+	_, r = RunSingle(`return reduce([1, 2, 3], fn(acc, x) { return acc * x })`)
+	assert.Equal(t, 6, r)
+
+	_, r = RunSingle(`
+d = {
+  a = 1
+  b = 2
+}
+return map(d, fn(v, k) { return k + str(v) })
+`)
+	assert.Equal(t, map[string]Value{"a": "a1", "b": "b2"}, r)
+}
+
+func TestFunnySort(t *testing.T) {
+	_, r := RunSingle(`return sort([3, 1.5, 2])`)
+	assert.Equal(t, []interface{}{1.5, 2, 3}, r)
+
+	_, r = RunSingle(`return sort(['b', 'c', 'a'])`)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, r)
+
+	_, r = RunSingle(`return sort([1, 3, 2], fn(a, b) { return a > b })`)
+	assert.Equal(t, []interface{}{3, 2, 1}, r)
+
+	_, r = RunSingle(`return sort([3, 1, 2], fn(a, b) { return b - a })`)
+	assert.Equal(t, []interface{}{3, 2, 1}, r)
+}
+
+func TestFunnyFindAnyAll(t *testing.T) {
+	_, r := RunSingle(`return find([1, 2, 3], fn(x) { return x > 1 })`)
+	assert.Equal(t, 2, r)
+
+	_, r = RunSingle(`return find([1, 2, 3], fn(x) { return x > 5 })`)
+	assert.Nil(t, r)
+
+	_, r = RunSingle(`return any([1, 2, 3], fn(x) { return x > 2 }) and not all([1, 2, 3], fn(x) { return x > 2 })`)
+	assert.Equal(t, true, r)
+
+	_, r = RunSingle(`return all([true, true]) and not any([false])`)
+	assert.Equal(t, true, r)
+}
+
+func TestFunnyGroupByUniq(t *testing.T) {
+	_, r := RunSingle(`return groupby([1, 2, 3, 4], fn(x) { return x > 2 })`)
+	assert.Equal(t, map[string]Value{
+		"false": []interface{}{1, 2},
+		"true":  []interface{}{3, 4},
+	}, r)
+
+	_, r = RunSingle(`return uniq([1, 2, 1, 'a', 'a', 2.0])`)
+	assert.Equal(t, []interface{}{1, 2, "a"}, r)
+
+	_, r = RunSingle(`return uniq(['a', 'ab', 'b'], len)`)
+	assert.Equal(t, []interface{}{"a", "ab"}, r)
+}
+
+func TestFunnyCallbackError(t *testing.T) {
+	i := NewFunny()
+	_, err := i.Run(`filter([1], fn(x) { return x })`)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "filter function must return [bool]")
+}
+
+func TestFunnyFunctionShadowBuiltin(t *testing.T) {
+	// a function defined by the script is called instead of the builtin, while a variable
+	// of another type is not
+	data := `
+len = len('abc')
+find(xs) {
+  return len + 1
+}
+return find([]) + len('ab')
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 6, r)
+
+	data = `
+map = {
+  upper = fn(s) { return s + '!' }
+}
+sort(xs) {
+  return 'sorted'
+}
+return [sort([2, 1]), map.upper('a'), filter([1, 2], fn(x) { return x > 1 })]
+`
+	_, r = RunSingle(data)
+	assert.Equal(t, []interface{}{"sorted", "a!", []interface{}{2}}, r)
+}
+
+func TestFunnyListIndex(t *testing.T) {
+	data := `
+xs = [1, 2, 3, 4]
+i = 1
+xs[i] = 20
+xs[-1] = 40
+return [xs, xs[-2], xs[i + 1]]
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, []interface{}{[]interface{}{1, 20, 3, 40}, 3, 3}, r)
+}
+
+func TestFunnySlice(t *testing.T) {
+	data := `
+xs = [1, 2, 3, 4]
+return [xs[1:3], xs[:-1], xs[2:], xs[5:], 'hello'[1:3], 'hello'[-1]]
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, []interface{}{
+		[]interface{}{2, 3},
+		[]interface{}{1, 2, 3},
+		[]interface{}{3, 4},
+		[]interface{}{},
+		"el",
+		"o",
+	}, r)
+}
+
+func TestFunnyChainedAccess(t *testing.T) {
+	data := `
+resp = {
+  data = [{
+    id = 7
+  }]
+}
+resp.data[0].id = resp.data[0].id + 1
+key = 'data'
+return resp[key][-1]['id']
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, 8, r)
+}
+
+func TestFunnyListIndexOutOfRange(t *testing.T) {
+	i := NewFunny()
+	_, err := i.Run(`
+xs = [1, 2]
+a = xs[2]
+`)
+	assert.NotNil(t, err)
+	fre := err.(*FunnyRuntimeError)
+	assert.Equal(t, "list index 2 out of range with length 2", fre.Msg)
+	assert.Equal(t, 2, fre.Postion.Line)
+}
+
+func TestFunnyListBuiltins(t *testing.T) {
+	data := `
+xs = [1, 2]
+ys = append(xs, 3, 4)
+return [xs, ys, pop(ys), pop(ys, 0), insert(xs, 1, 9), insert(xs, 2, 9), remove(ys, -1)]
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, []interface{}{
+		[]interface{}{1, 2},
+		[]interface{}{1, 2, 3, 4},
+		[]interface{}{4, []interface{}{1, 2, 3}},
+		[]interface{}{1, []interface{}{2, 3, 4}},
+		[]interface{}{1, 9, 2},
+		[]interface{}{1, 2, 9},
+		[]interface{}{1, 2, 3},
+	}, r)
+
+	// the lists given are never changed, the new ones are assigned back
+	data = `
+xs = [1, 2, 3]
+ys = xs
+append(xs, 4)
+insert(xs, 0, 0)
+remove(xs, 0)
+pop(xs)
+xs = remove(append(xs, 4), 0)
+r = pop(xs, -2)
+xs = r[1]
+return [xs, ys, r[0]]
+`
+	_, r = RunSingle(data)
+	assert.Equal(t, []interface{}{
+		[]interface{}{2, 4},
+		[]interface{}{1, 2, 3},
+		3,
+	}, r)
+
+	_, err := NewFunny().Run("pop([])")
+	assert.Equal(t, "pop from empty list", err.(*FunnyRuntimeError).Msg)
+	_, err = NewFunny().Run("pop([1], 1)")
+	assert.Equal(t, "pop index 1 out of range with length 1", err.(*FunnyRuntimeError).Msg)
+}
+
+func TestFunnyStringInterpolation(t *testing.T) {
+	data := `
+name = 'funny'
+d = {
+  n = 1
+}
+return "hello ${name}, ${d.n + 1} ${[1, 2]} \${name}"
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, "hello funny, 2 [1 2] ${name}", r)
+}
+
+func TestFunnyMultiLineString(t *testing.T) {
+	data := `
+table = 't'
+return """
+select *
+  from ${table}
+ where a = 'x'
+"""
+`
+	_, r := RunSingle(data)
+	assert.Equal(t, "\nselect *\n  from t\n where a = 'x'\n", r)
+}
+
+func TestFunnyCall(t *testing.T) {
+	fn := NewFunny()
+	_, err := fn.Run(`
+count = 0
+onResponse(resp) {
+  return resp.status == 200
+}
+fail(msg) {
+  throw(msg)
+}
+handlers = {
+  prefix = 'hi '
+  greet(name) {
+    return prefix + name
+  }
+}
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fail", "onResponse"}, fn.Functions())
+
+	r, err := fn.Call("onResponse", map[string]interface{}{"status": 200})
+	assert.Nil(t, err)
+	assert.Equal(t, true, r)
+
+	r, err = fn.Call("handlers.greet", "funny")
+	assert.Nil(t, err)
+	assert.Equal(t, "hi funny", r)
+
+	r, err = fn.Call("len", []int{1, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r)
+
+	scopes := len(fn.Vars)
+	_, err = fn.Call("fail", "boom")
+	assert.Equal(t, "boom", err.(*FunnyRuntimeError).Msg)
+	assert.Equal(t, "fail", err.(*FunnyRuntimeError).Stack[len(err.(*FunnyRuntimeError).Stack)-1].Name)
+	assert.Equal(t, scopes, len(fn.Vars))
+	assert.Equal(t, 0, len(fn.Stack))
+
+	_, err = fn.Call("onResponse")
+	assert.NotNil(t, err)
+	_, err = fn.Call("missing")
+	assert.Equal(t, "function [missing] not defined", err.(*FunnyRuntimeError).Msg)
+	_, err = fn.Call("count")
+	assert.NotNil(t, err)
+}
+
+func TestFunnyRedirectIO(t *testing.T) {
+	stdout := new(bytes.Buffer)
+	fn := NewFunny()
+	fn.Stdout = stdout
+	fn.Stdin = strings.NewReader("funny\r\nlast")
+	_, err := fn.Run(`
+name = input('name? ')
+echoln('hello ', name)
+echo({a = 1})
+echoln()
+echoln(readline())
+echoln(readline() == nil)
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "name? hello funny\n{\"a\":1}\nlast\ntrue\n", stdout.String())
+}
@@ -17,5 +17,6 @@ require (
 	github.com/spf13/viper v1.9.0
 	github.com/stretchr/testify v1.7.0
 	go.uber.org/zap v1.19.1
+	golang.org/x/term v0.0.0-20210422114643-f5beecf764ed
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1
 )
@@ -0,0 +1,277 @@
+package funny
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP_METHODS the methods http.request can send
+var HTTP_METHODS = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// WithHTTPClient send the requests of http builtins by client, like one with a proxy or
+// its own TLS config
+func WithHTTPClient(client *http.Client) Option {
+	return func(fn *Funny) {
+		fn.HTTPClient = client
+	}
+}
+
+// httpClient the client http builtins use, every redirect is checked by the capabilities
+// like the url requested
+func (i *Funny) httpClient() *http.Client {
+	client := http.DefaultClient
+	if i.HTTPClient != nil {
+		client = i.HTTPClient
+	}
+	if i.Capabilities == nil {
+		return client
+	}
+	checked := *client
+	next := client.CheckRedirect
+	checked.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := i.urlError(req.URL.String()); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		// the default policy of http.Client
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &checked
+}
+
+// HttpSend http.request(options) send the request given by options, which are method, url,
+// query, headers, one of json, form and body, and timeout like '5s' or milliseconds. The
+// response is {status, headers, body, json, elapsedMs}, json is nil if body is not json,
+// and responses like 404 are returned rather than raised
+func HttpSend(fn *Funny, args []Value) Value {
+	ackEq(fn, args, 1)
+	options, ok := asDict(args[0])
+	if !ok {
+		panic(P(fmt.Sprintf("http.request type error, options only support [dict] given [%s]", Typing(args[0])), fn.Current))
+	}
+	req, cancel := newHttpRequest(fn, options)
+	defer cancel()
+	start := time.Now()
+	resp, err := fn.httpClient().Do(req)
+	if err != nil {
+		// the method and url are in the message already
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		panic(&FunnyRuntimeError{
+			Postion: fn.Current,
+			Msg:     fmt.Sprintf("http.request %s %s: %s", req.Method, req.URL, err),
+			Err:     err,
+		})
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		panic(&FunnyRuntimeError{
+			Postion: fn.Current,
+			Msg:     fmt.Sprintf("http.request %s %s: read response: %s", req.Method, req.URL, err),
+			Err:     err,
+		})
+	}
+	elapsed := time.Since(start)
+	headers := make(map[string]Value, len(resp.Header))
+	for key, values := range resp.Header {
+		headers[strings.ToLower(key)] = Value(strings.Join(values, ", "))
+	}
+	return Value(map[string]Value{
+		"status":    Value(resp.StatusCode),
+		"headers":   Value(headers),
+		"body":      Value(string(body)),
+		"json":      decodeJSON(body),
+		"elapsedMs": Value(float64(elapsed.Microseconds()) / 1000),
+	})
+}
+
+// newHttpRequest build the request of http.request options, cancel must be called after
+// the response is read
+func newHttpRequest(fn *Funny, options map[string]Value) (*http.Request, context.CancelFunc) {
+	for key := range options {
+		switch key {
+		case "method", "url", "query", "headers", "json", "form", "body", "timeout":
+		default:
+			panic(P(fmt.Sprintf("http.request unknown option [%s]", key), fn.Current))
+		}
+	}
+	method := "GET"
+	if m, ok := options["method"]; ok && m != nil {
+		s, _ := m.(string)
+		method = ""
+		for _, item := range HTTP_METHODS {
+			if strings.EqualFold(item, s) {
+				method = item
+			}
+		}
+		if method == "" {
+			panic(P(fmt.Sprintf("http.request method should be one of %s given [%v]", strings.Join(HTTP_METHODS, ", "), m), fn.Current))
+		}
+	}
+	rawURL, ok := options["url"].(string)
+	if !ok || rawURL == "" {
+		panic(P("http.request url is required", fn.Current))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(P(err.Error(), fn.Current))
+	}
+	if query, ok := options["query"]; ok && query != nil {
+		values := u.Query()
+		for key, val := range httpValues(fn, "query", query) {
+			values[key] = append(values[key], val...)
+		}
+		u.RawQuery = values.Encode()
+	}
+	fn.CheckURL(u.String())
+
+	var body io.Reader
+	contentType := ""
+	given := 0
+	if data, ok := options["json"]; ok {
+		given++
+		bts, err := json.Marshal(data)
+		if err != nil {
+			panic(P(fmt.Sprintf("http.request json: %s", err), fn.Current))
+		}
+		body, contentType = bytes.NewReader(bts), "application/json"
+	}
+	if form, ok := options["form"]; ok {
+		given++
+		body, contentType = strings.NewReader(httpValues(fn, "form", form).Encode()), "application/x-www-form-urlencoded"
+	}
+	if data, ok := options["body"]; ok {
+		given++
+		s, ok := data.(string)
+		if !ok {
+			panic(P(fmt.Sprintf("http.request type error, body only support [string] given [%s]", Typing(data)), fn.Current))
+		}
+		body = strings.NewReader(s)
+	}
+	if given > 1 {
+		panic(P("http.request only one of json, form and body can be given", fn.Current))
+	}
+
+	ctx, cancel := fn.Context(), context.CancelFunc(func() {})
+	if timeout, ok := options["timeout"]; ok && timeout != nil {
+		ctx, cancel = context.WithTimeout(ctx, httpTimeout(fn, timeout))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		cancel()
+		panic(P(err.Error(), fn.Current))
+	}
+	req.Header.Set("User-Agent", "Funny HttpRequest")
+	req.Header.Set("Accept", "*/*")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := options["headers"]; ok && headers != nil {
+		for key, values := range httpValues(fn, "headers", headers) {
+			req.Header.Del(key)
+			for _, val := range values {
+				req.Header.Add(key, val)
+			}
+		}
+	}
+	return req, cancel
+}
+
+// httpValues the values of dict option name, which are strings, numbers, bools or lists of them
+func httpValues(fn *Funny, name string, option Value) url.Values {
+	dict, ok := asDict(option)
+	if !ok {
+		panic(P(fmt.Sprintf("http.request type error, %s only support [dict] given [%s]", name, Typing(option)), fn.Current))
+	}
+	values := url.Values{}
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		items, ok := dict[key].([]interface{})
+		if !ok {
+			items = []interface{}{dict[key]}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case string, int, float64, bool:
+				values.Add(key, fmt.Sprint(item))
+			default:
+				panic(P(fmt.Sprintf("http.request type error, %s %s only support [string, int, float, bool] given [%s]", name, key, Typing(item)), fn.Current))
+			}
+		}
+	}
+	return values
+}
+
+// httpTimeout the duration of timeout option, which is a duration like 5s or milliseconds
+func httpTimeout(fn *Funny, timeout Value) time.Duration {
+	switch t := timeout.(type) {
+	case int:
+		return time.Duration(t) * time.Millisecond
+	case float64:
+		return time.Duration(t * float64(time.Millisecond))
+	case string:
+		if d, err := time.ParseDuration(t); err == nil {
+			return d
+		}
+	}
+	panic(P(fmt.Sprintf("http.request timeout should be like '5s' or milliseconds given [%v]", timeout), fn.Current))
+}
+
+// decodeJSON the funny value of data if it is json, or nil. Integers are int rather than float
+func decodeJSON(data []byte) Value {
+	if !json.Valid(data) {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil
+	}
+	return jsonValue(v)
+}
+
+func jsonValue(v interface{}) Value {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := strconv.Atoi(v.String()); err == nil {
+			return Value(n)
+		}
+		f, _ := v.Float64()
+		return Value(f)
+	case []interface{}:
+		for index, item := range v {
+			v[index] = jsonValue(item)
+		}
+		return Value(v)
+	case map[string]interface{}:
+		dict := make(map[string]Value, len(v))
+		for key, item := range v {
+			dict[key] = jsonValue(item)
+		}
+		return Value(dict)
+	}
+	return Value(v)
+}
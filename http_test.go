@@ -0,0 +1,118 @@
+package funny
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEchoServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		case "/list":
+			_, _ = w.Write([]byte(`[1, 2.5, {"a": true}]`))
+		default:
+			w.Header().Set("X-Method", r.Method)
+			w.Header().Set("X-Query", r.URL.RawQuery)
+			w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+			w.Header().Set("X-Token", r.Header.Get("X-Token"))
+			_, _ = w.Write(body)
+		}
+	}))
+}
+
+func TestHttpSend(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+	fn := NewFunny()
+	fn.Assign("base", server.URL)
+	_, err := fn.Run(`
+resp = http.request({
+  method = 'patch'
+  url = base + '/echo?a=1'
+  query = {
+    b = [2, 'x']
+  }
+  headers = {
+    'X-Token' = 'secret'
+  }
+  json = {
+    name = 'funny'
+  }
+})
+missing = http.request({
+  url = base + '/missing'
+})
+list = http.request({
+  url = base + '/list'
+})
+form = http.request({
+  method = 'POST'
+  url = base
+  form = {
+    q = 'a b'
+  }
+})
+`)
+	assert.Nil(t, err)
+
+	resp := fn.Lookup("resp").(map[string]Value)
+	assert.Equal(t, 200, resp["status"])
+	headers := resp["headers"].(map[string]Value)
+	assert.Equal(t, "PATCH", headers["x-method"])
+	assert.Equal(t, "a=1&b=2&b=x", headers["x-query"])
+	assert.Equal(t, "application/json", headers["x-content-type"])
+	assert.Equal(t, "secret", headers["x-token"])
+	assert.Equal(t, `{"name":"funny"}`, resp["body"])
+	assert.Equal(t, map[string]Value{"name": "funny"}, resp["json"])
+	assert.IsType(t, float64(0), resp["elapsedMs"])
+
+	missing := fn.Lookup("missing").(map[string]Value)
+	assert.Equal(t, 404, missing["status"])
+	assert.Equal(t, "not found", missing["body"])
+	assert.Nil(t, missing["json"])
+
+	list := fn.Lookup("list").(map[string]Value)
+	assert.Equal(t, []interface{}{1, 2.5, map[string]Value{"a": true}}, list["json"])
+
+	form := fn.Lookup("form").(map[string]Value)
+	assert.Equal(t, "q=a+b", form["body"])
+	assert.Equal(t, "application/x-www-form-urlencoded", form["headers"].(map[string]Value)["x-content-type"])
+}
+
+func TestHttpSendErrors(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+	for code, msg := range map[string]string{
+		"http.request({\n  url = base\n  method = 'TRACE'\n})":       "http.request method should be one of GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS given [TRACE]",
+		"http.request({\n  url = base\n  json = 1\n  body = 'x'\n})": "http.request only one of json, form and body can be given",
+		"http.request({\n  url = base\n  retries = 1\n})":            "http.request unknown option [retries]",
+		"http.request({\n  method = 'GET'\n})":                       "http.request url is required",
+		"http.request({\n  url = base\n  timeout = 'soon'\n})":       "http.request timeout should be like '5s' or milliseconds given [soon]",
+	} {
+		fn := NewFunny()
+		fn.Assign("base", server.URL)
+		_, err := fn.Run(code)
+		if assert.NotNil(t, err, code) {
+			assert.Equal(t, msg, err.(*FunnyRuntimeError).Msg, code)
+		}
+	}
+}
+
+func TestHttpSendSandboxed(t *testing.T) {
+	server := newEchoServer()
+	defer server.Close()
+	fn := NewFunny(WithCapabilities(&Capabilities{}))
+	fn.Assign("base", server.URL)
+	_, err := fn.Run("http.request({\n  url = base\n})")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+}
@@ -2,6 +2,7 @@ package funny
 
 import (
 	"fmt"
+	"strings"
 	"unicode/utf8"
 )
 
@@ -128,17 +129,48 @@ func isNameStart(ch rune) bool {
 	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
 }
 
-// ReadInt get a into from current position
-func (l *Lexer) ReadInt() Token {
-	for {
-		ch := l.LA(1)
-		switch ch {
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+// isName check whether s can be written as a NAME token
+func isName(s string) bool {
+	for index, ch := range s {
+		if !isNameStart(ch) && (index == 0 || !isDigit(ch)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDigit(ch rune) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// ReadNumber get an int or a float like 1.5 or 1e3 from current position
+func (l *Lexer) ReadNumber() Token {
+	kind := INT
+	for isDigit(l.LA(1)) {
+		l.Consume(1)
+	}
+	if l.LA(1) == '.' && isDigit(l.LA(2)) {
+		kind = FLOAT
+		l.Consume(1)
+		for isDigit(l.LA(1)) {
 			l.Consume(1)
-		default:
-			return l.CreateToken(INT)
 		}
 	}
+	if ch := l.LA(1); ch == 'e' || ch == 'E' {
+		if isDigit(l.LA(2)) {
+			kind = FLOAT
+			l.Consume(1)
+		} else if sign := l.LA(2); (sign == '+' || sign == '-') && isDigit(l.LA(3)) {
+			kind = FLOAT
+			l.Consume(2)
+		}
+		if kind == FLOAT {
+			for isDigit(l.LA(1)) {
+				l.Consume(1)
+			}
+		}
+	}
+	return l.CreateToken(kind)
 }
 
 // Reset reset the position
@@ -168,9 +200,10 @@ func (l *Lexer) Next() Token {
 				l.Consume(2)
 				return l.ReadComments()
 			}
+			l.Consume(1)
 			return l.CreateToken(DEVIDE)
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			return l.ReadInt()
+			return l.ReadNumber()
 		case '=':
 			if l.LA(2) == '=' {
 				l.Consume(2)
@@ -208,6 +241,9 @@ func (l *Lexer) Next() Token {
 		case ',':
 			l.Consume(1)
 			return l.CreateToken(COMMA)
+		case ':':
+			l.Consume(1)
+			return l.CreateToken(COLON)
 		case '.':
 			l.Consume(1)
 			return l.CreateToken(DOT)
@@ -230,11 +266,9 @@ func (l *Lexer) Next() Token {
 				l.Consume(2)
 				return l.CreateToken(NOTEQ)
 			}
-		case '\'':
-			if l.LA(2) == '"' {
-				l.Consume(2)
-				return l.CreateToken(STRING)
-			}
+			l.Consume(1)
+			return l.CreateToken(BANG)
+		case '\'', '"':
 			return l.ReadString()
 		default:
 			if isNameStart(ch) {
@@ -255,23 +289,119 @@ func (l *Lexer) Next() Token {
 	}
 }
 
-// ReadString read next string token
+// ReadString read a string quoted by ' or ", three quotes start a multi-line string.
+// Escapes are decoded in the token data, but a double quoted string containing ${expr}
+// is a TEMPLATE token with raw data which is split by the parser
 func (l *Lexer) ReadString() Token {
-	// TODO: Fix (using state machine)
-	l.Consume(1)
 	l.Reset()
-
+	quote := l.LA(1)
+	delimiter := 1
+	if l.LA(2) == quote && l.LA(3) == quote {
+		delimiter = 3
+	}
+	l.Consume(delimiter)
+	start := l.Offset
+	kind := STRING
 	for {
 		ch := l.LA(1)
-		switch ch {
-		case '\'':
-			token := l.CreateToken(STRING)
-			l.Consume(1)
-			return token
+		switch {
+		case l.Offset >= len(l.Data):
+			panic(P("string not terminated", l.SavePos))
+		case ch == '\\':
+			l.consumeChar()
+			if l.Offset < len(l.Data) {
+				l.consumeChar()
+			}
+		case ch == quote && (delimiter == 1 || (l.LA(2) == quote && l.LA(3) == quote)):
+			data := string(l.Data[start:l.Offset])
+			l.Consume(delimiter)
+			if kind == STRING {
+				data = Unescape(data)
+			}
+			return Token{
+				Kind: kind,
+				Data: data,
+				Position: Position{
+					Col:    l.SavePos.Col,
+					Line:   l.SavePos.Line,
+					Length: l.Offset - l.SaveOffset,
+					File:   l.File,
+				},
+			}
+		case ch == '$' && quote == '"' && l.LA(2) == '{':
+			kind = TEMPLATE
+			end := InterpolationEnd(l.Data, l.Offset)
+			if end < 0 {
+				panic(P("string interpolation not closed", l.CurrentPos))
+			}
+			for l.Offset < end {
+				l.consumeChar()
+			}
 		default:
-			l.Consume(1)
+			l.consumeChar()
+		}
+	}
+}
+
+// consumeChar consume one char, and move to next line if it is a new line
+func (l *Lexer) consumeChar() {
+	if l.Consume(1) == '\n' {
+		l.CurrentPos.Col = 0
+		l.CurrentPos.Line++
+	}
+}
+
+// InterpolationEnd get the offset after the } closing the ${ at offset of data, -1 if not closed.
+// Braces and quoted strings inside the expression are skipped
+func InterpolationEnd(data []byte, offset int) int {
+	depth := 0
+	for index := offset + 1; index < len(data); index++ {
+		switch ch := data[index]; ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return index + 1
+			}
+		case '\'', '"':
+			for index++; index < len(data) && data[index] != ch; index++ {
+				if data[index] == '\\' {
+					index++
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// Unescape decode escapes \n, \t, \r, \\, \', \" and \$ of string, other backslashes are kept
+func Unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for index := 0; index < len(s); index++ {
+		if s[index] != '\\' || index+1 == len(s) {
+			b.WriteByte(s[index])
+			continue
+		}
+		switch next := s[index+1]; next {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '\\', '\'', '"', '$':
+			b.WriteByte(next)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(next)
 		}
+		index++
 	}
+	return b.String()
 }
 
 // ReadComments read comments
@@ -225,3 +225,77 @@ if 1 in [1,2] {
 	}
 	assert.NotEmpty(t, tokens)
 }
+
+func TestLexerNumbers(t *testing.T) {
+	cases := []struct {
+		code string
+		kind string
+		data string
+	}{
+		{"12", INT, "12"},
+		{"1.5", FLOAT, "1.5"},
+		{"1e3", FLOAT, "1e3"},
+		{"2.5E-3", FLOAT, "2.5E-3"},
+		{"1.", INT, "1"},
+		{"1else", INT, "1"},
+	}
+	for _, item := range cases {
+		lexer := NewLexer([]byte(item.code), "")
+		token := lexer.Next()
+		assert.Equal(t, item.kind, token.Kind, item.code)
+		assert.Equal(t, item.data, token.Data, item.code)
+	}
+}
+
+func TestLexerStrings(t *testing.T) {
+	cases := []struct {
+		code string
+		kind string
+		data string
+	}{
+		{`'abc'`, STRING, "abc"},
+		{`''`, STRING, ""},
+		{`'"'`, STRING, `"`},
+		{`"it's"`, STRING, "it's"},
+		{`'a\nb\tc\'d\\e\"f'`, STRING, "a\nb\tc'd\\e\"f"},
+		{`'\d+'`, STRING, `\d+`},
+		{"'''a\n'b'\n'''", STRING, "a\n'b'\n"},
+		{`"a ${b} c"`, TEMPLATE, "a ${b} c"},
+		{`"a ${d["k"]}"`, TEMPLATE, `a ${d["k"]}`},
+		{`"a \${b}"`, STRING, "a ${b}"},
+		{`'a ${b}'`, STRING, "a ${b}"},
+	}
+	for _, item := range cases {
+		lexer := NewLexer([]byte(item.code), "")
+		token := lexer.Next()
+		assert.Equal(t, item.kind, token.Kind, item.code)
+		assert.Equal(t, item.data, token.Data, item.code)
+		assert.Equal(t, EOF, lexer.Next().Kind, item.code)
+	}
+}
+
+func TestLexerMultiLineStringPosition(t *testing.T) {
+	lexer := NewLexer([]byte("a = '''x\ny'''\nb"), "")
+	for {
+		token := lexer.Next()
+		if token.Kind == NAME && token.Data == "b" {
+			assert.Equal(t, 2, token.Position.Line)
+			assert.Equal(t, 0, token.Position.Col)
+			break
+		}
+		if token.Kind == EOF {
+			t.Fatal("name b not found")
+		}
+	}
+}
+
+func TestLexerUnterminatedString(t *testing.T) {
+	for _, code := range []string{"a = 'abc", "a = \"x ${b", "a = '''x''"} {
+		parser := NewParser([]byte(code), "")
+		_, err := parser.Parse()
+		assert.NotNil(t, err, code)
+		fre, ok := err.(*FunnyRuntimeError)
+		assert.True(t, ok, code)
+		assert.Equal(t, 0, fre.Postion.Line, code)
+	}
+}
@@ -0,0 +1,130 @@
+package funny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxDepth the call depth limit when Limits.MaxDepth is 0, deep enough for recursive
+// scripts and far from overflowing the go stack
+const DefaultMaxDepth = 10000
+
+var (
+	// ErrStepLimit the error caused by running more statements than Limits.MaxSteps
+	ErrStepLimit = errors.New("step limit exceeded")
+	// ErrDepthLimit the error caused by nesting more function calls than Limits.MaxDepth
+	ErrDepthLimit = errors.New("call depth limit exceeded")
+	// ErrTimeLimit the error caused by running longer than Limits.Timeout
+	ErrTimeLimit = errors.New("time limit exceeded")
+)
+
+// Limits the budgets of running code, exceeding any of them raises an error which can be
+// caught by try statement, and is ErrStepLimit, ErrDepthLimit or ErrTimeLimit by errors.Is
+type Limits struct {
+	// MaxSteps the most statements run, unlimited if it is 0
+	MaxSteps int
+	// MaxDepth the most nested function calls, DefaultMaxDepth if it is 0 and unlimited
+	// if it is negative
+	MaxDepth int
+	// Timeout the most wall time, unlimited if it is 0
+	Timeout time.Duration
+}
+
+// WithLimits run code with limits
+func WithLimits(limits Limits) Option {
+	return func(fn *Funny) {
+		fn.Limits = limits
+	}
+}
+
+// runState the context and the budgets used by the code running
+type runState struct {
+	ctx context.Context
+	// parent the context given by the caller, ctx has the timeout of limits in addition
+	parent context.Context
+	done   <-chan struct{}
+	steps  int
+}
+
+// Context the context of the code running, builtins doing I/O should stop when it is done
+func (i *Funny) Context() context.Context {
+	if i.state.ctx == nil {
+		return context.Background()
+	}
+	return i.state.ctx
+}
+
+// Begin start running code under ctx and the limits until the returned function is called.
+// A nested run counts its steps into the outer one, and restores its context when it ends
+func (i *Funny) Begin(ctx context.Context) func() {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	outer := i.state
+	if outer.ctx == ctx {
+		// nested run under the context of the running code, like Run called by builtins
+		return func() {}
+	}
+	parent := ctx
+	cancel := func() {}
+	if i.Limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, i.Limits.Timeout)
+	}
+	i.state = runState{
+		ctx:    ctx,
+		parent: parent,
+		done:   ctx.Done(),
+	}
+	if outer.ctx != nil {
+		i.state.steps = outer.steps
+	}
+	return func() {
+		cancel()
+		steps := i.state.steps
+		i.state = outer
+		if outer.ctx != nil {
+			i.state.steps = steps
+		}
+	}
+}
+
+// Step count one statement at pos, it raises error if the steps are more than the limit or
+// the context is done
+func (i *Funny) Step(pos Position) {
+	i.state.steps++
+	if i.Limits.MaxSteps > 0 && i.state.steps > i.Limits.MaxSteps {
+		panic(limitError(ErrStepLimit, fmt.Sprintf("step limit %d exceeded", i.Limits.MaxSteps), pos))
+	}
+	if i.state.done == nil {
+		return
+	}
+	select {
+	case <-i.state.done:
+		if err := i.state.parent.Err(); err != nil {
+			panic(limitError(err, fmt.Sprintf("execution cancelled: %s", err), pos))
+		}
+		panic(limitError(ErrTimeLimit, fmt.Sprintf("time limit %s exceeded", i.Limits.Timeout), pos))
+	default:
+	}
+}
+
+// checkDepth raise error if one more call at pos is deeper than the limit
+func (i *Funny) checkDepth(pos Position) {
+	max := i.Limits.MaxDepth
+	if max == 0 {
+		max = DefaultMaxDepth
+	}
+	if max > 0 && len(i.Stack) >= max {
+		panic(limitError(ErrDepthLimit, fmt.Sprintf("call depth limit %d exceeded", max), pos))
+	}
+}
+
+func limitError(err error, msg string, pos Position) *FunnyRuntimeError {
+	return &FunnyRuntimeError{
+		Postion: pos,
+		Msg:     msg,
+		Err:     err,
+	}
+}
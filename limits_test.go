@@ -0,0 +1,130 @@
+package funny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitSteps(t *testing.T) {
+	fn := NewFunny(WithLimits(Limits{
+		MaxSteps: 8,
+	}))
+	_, err := fn.Run(`
+total = 0
+for index, item in [1, 2, 3, 4, 5, 6, 7, 8, 9, 10] {
+  total = total + item
+}
+`)
+	assert.True(t, errors.Is(err, ErrStepLimit))
+	assert.Equal(t, "step limit 8 exceeded", err.(*FunnyRuntimeError).Msg)
+	assert.Equal(t, 3, err.(*FunnyRuntimeError).Postion.Line)
+	assert.Equal(t, 21, fn.Vars[0]["total"])
+
+	// the budget is for every run
+	r, err := fn.Run("return 1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r)
+}
+
+func TestLimitDepth(t *testing.T) {
+	fn := NewFunny()
+	r, err := fn.Run(`
+f(n) {
+  return f(n + 1)
+}
+try {
+  f(0)
+} catch err {
+  return err.message
+}
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "call depth limit 10000 exceeded", r)
+
+	fn = NewFunny(WithLimits(Limits{
+		MaxDepth: 3,
+	}))
+	_, err = fn.Run(`
+f(n) {
+  if n == 0 {
+    return 0
+  }
+  return f(n - 1)
+}
+f(5)
+`)
+	assert.True(t, errors.Is(err, ErrDepthLimit))
+	assert.Equal(t, 3, len(err.(*FunnyRuntimeError).Stack))
+	assert.Equal(t, 0, len(fn.Stack))
+
+	// only the innermost and outermost frames are kept when the calls are deep
+	fn = NewFunny()
+	r, err = fn.Run(`
+f(n) {
+  return f(n + 1)
+}
+try {
+  f(0)
+} catch err {
+  return [len(err.stack), err.elided]
+}
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{MaxStackFrames, DefaultMaxDepth - MaxStackFrames}, r)
+
+	_, err = NewFunny().Run("f(n) {\n  return f(n + 1)\n}\nf(0)\n")
+	fre := err.(*FunnyRuntimeError)
+	assert.Equal(t, MaxStackFrames, len(fre.Stack))
+	trace := strings.Split(strings.TrimRight(fre.StackTrace(), "\n"), "\n")
+	assert.Equal(t, MaxStackFrames+1, len(trace))
+	assert.Equal(t, fmt.Sprintf("  ... %d more frames", DefaultMaxDepth-MaxStackFrames), trace[MaxStackFrames/2])
+	assert.Equal(t, "  at f (:4:3)", trace[len(trace)-1])
+}
+
+const slowScript = `
+fib(n) {
+  if n < 2 {
+    return n
+  }
+  return fib(n - 1) + fib(n - 2)
+}
+fib(40)
+`
+
+func TestLimitTimeout(t *testing.T) {
+	fn := NewFunny(WithLimits(Limits{
+		Timeout: 20 * time.Millisecond,
+	}))
+	start := time.Now()
+	_, err := fn.Run(slowScript)
+	assert.True(t, errors.Is(err, ErrTimeLimit))
+	assert.Equal(t, "time limit 20ms exceeded", err.(*FunnyRuntimeError).Msg)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, context.Background(), fn.Context())
+}
+
+func TestRunContextCancel(t *testing.T) {
+	fn := NewFunny()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_, err := fn.RunContext(ctx, slowScript)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, "execution cancelled: context canceled", err.(*FunnyRuntimeError).Msg)
+	assert.Equal(t, 0, len(fn.Stack))
+
+	fn.RegisterFunction("ctxerr", func(fn *Funny, args []Value) Value {
+		return fn.Context().Err() == nil
+	})
+	r, err := fn.Run("return ctxerr()")
+	assert.Nil(t, err)
+	assert.Equal(t, true, r)
+}
@@ -64,6 +64,13 @@ func findHover(logger *zap.Logger, blocks []*funny.Block, hoverToken *funny.Toke
 	for _, block := range blocks {
 		for _, statement := range block.Statements {
 			switch v := statement.(type) {
+			case *funny.Assign:
+				// the functions of dicts like http.request
+				if dict, ok := v.Value.(*funny.Block); ok {
+					if hover := findHover(logger, []*funny.Block{dict}, hoverToken); hover != nil {
+						return hover
+					}
+				}
 			case *funny.Function:
 				if hoverToken.Data == v.Name {
 					// lastPos := v.Body.Statements[len(v.Body.Statements)-1].GetPosition()
@@ -1,7 +1,12 @@
 package funny
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strings"
+	"time"
 )
 
 // Typing return the type name of one object
@@ -12,3 +17,406 @@ func Typing(data interface{}) string {
 	}
 	return t.String()
 }
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	funnyPtrType = reflect.TypeOf((*Funny)(nil))
+)
+
+// ToValue convert go value v into the value used by funny code. Numbers become int or
+// float64, slices and arrays become lists, maps and structs become dicts, and pointers
+// are followed. Struct fields are named by the funny tag, or the json tag if not given,
+// like `funny:"name,omitempty"`, and are skipped if the name is -
+func ToValue(v interface{}) Value {
+	switch v := v.(type) {
+	case nil, bool, int, float64, string, time.Time, BuiltinFunction, Callable:
+		return Value(v)
+	}
+	return toValue(reflect.ValueOf(v))
+}
+
+func toValue(rv reflect.Value) Value {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Bool:
+		return Value(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Value(int(rv.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Value(int(rv.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return Value(rv.Float())
+	case reflect.String:
+		return Value(rv.String())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return ToValue(rv.Elem().Interface())
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Value(string(rv.Bytes()))
+		}
+		fallthrough
+	case reflect.Array:
+		ls := make([]interface{}, rv.Len())
+		for index := range ls {
+			ls[index] = toValue(rv.Index(index))
+		}
+		return Value(ls)
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		dict := make(map[string]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			dict[fmt.Sprint(iter.Key().Interface())] = toValue(iter.Value())
+		}
+		return Value(dict)
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			return Value(rv.Interface())
+		}
+		dict := make(map[string]Value)
+		for _, f := range structFields(rv.Type()) {
+			field := rv.FieldByIndex(f.index)
+			if f.omitEmpty && field.IsZero() {
+				continue
+			}
+			dict[f.name] = toValue(field)
+		}
+		return Value(dict)
+	}
+	if rv.CanInterface() {
+		return Value(rv.Interface())
+	}
+	return nil
+}
+
+// FromValue store funny value v into the go value out points to, converting it the
+// reverse way of ToValue. Times can also be given as RFC 3339 strings
+func FromValue(v Value, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("out must be a non-nil pointer but got %s", Typing(out))
+	}
+	return fromValue(v, rv.Elem(), "")
+}
+
+func fromValue(v Value, rv reflect.Value, path string) error {
+	t := rv.Type()
+	if v == nil {
+		rv.Set(reflect.Zero(t))
+		return nil
+	}
+	mismatch := func() error {
+		if path == "" {
+			return fmt.Errorf("can not convert %s to %s", Typing(v), t)
+		}
+		return fmt.Errorf("can not convert %s to %s at %s", Typing(v), t, path)
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		src := reflect.ValueOf(v)
+		if !src.Type().AssignableTo(t) {
+			return mismatch()
+		}
+		rv.Set(src)
+		return nil
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := fromValue(v, elem.Elem(), path); err != nil {
+			return err
+		}
+		rv.Set(elem)
+		return nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return mismatch()
+		}
+		rv.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		rv.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toNumber(v)
+		i, isInt := n.(int)
+		if !ok || !isInt || rv.OverflowInt(int64(i)) {
+			return mismatch()
+		}
+		rv.SetInt(int64(i))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := toNumber(v)
+		i, isInt := n.(int)
+		if !ok || !isInt || i < 0 || rv.OverflowUint(uint64(i)) {
+			return mismatch()
+		}
+		rv.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := toNumber(v)
+		if !ok {
+			return mismatch()
+		}
+		f := toFloat(n)
+		if t.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 {
+			return mismatch()
+		}
+		rv.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if s, ok := v.(string); ok && t.Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes([]byte(s))
+			return nil
+		}
+		ls, ok := listOf(v)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(t, len(ls), len(ls))
+		for index, item := range ls {
+			if err := fromValue(item, slice.Index(index), fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		ls, ok := listOf(v)
+		if !ok || len(ls) != t.Len() {
+			return mismatch()
+		}
+		for index, item := range ls {
+			if err := fromValue(item, rv.Index(index), fmt.Sprintf("%s[%d]", path, index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		dict, ok := dictOf(v)
+		if !ok || t.Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(t, len(dict))
+		for key, item := range dict {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := fromValue(item, elem, joinPath(path, key)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		rv.Set(m)
+		return nil
+	case reflect.Struct:
+		if t == timeType {
+			switch v := v.(type) {
+			case time.Time:
+				rv.Set(reflect.ValueOf(v))
+				return nil
+			case string:
+				tm, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return mismatch()
+				}
+				rv.Set(reflect.ValueOf(tm))
+				return nil
+			}
+			return mismatch()
+		}
+		dict, ok := dictOf(v)
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range structFields(t) {
+			item, exists := dict[f.name]
+			if !exists {
+				continue
+			}
+			if err := fromValue(item, rv.FieldByIndex(f.index), joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return mismatch()
+}
+
+// listOf the items of funny list v
+func listOf(v Value) ([]Value, bool) {
+	switch v := v.(type) {
+	case []interface{}:
+		ls := make([]Value, len(v))
+		for index, item := range v {
+			ls[index] = item
+		}
+		return ls, true
+	case []Value:
+		return v, true
+	case *[]Value:
+		return *v, true
+	}
+	return nil, false
+}
+
+// dictOf the fields of funny dict v
+func dictOf(v Value) (map[string]Value, bool) {
+	switch v := v.(type) {
+	case map[string]Value:
+		return v, true
+	case Scope:
+		return v, true
+	case map[string]interface{}:
+		dict := make(map[string]Value, len(v))
+		for key, item := range v {
+			dict[key] = item
+		}
+		return dict, true
+	}
+	return nil, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// structField one field of a struct converted from and to a dict
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields the exported fields of struct type t, the fields of embedded structs
+// without name are promoted like encoding/json does
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for index := 0; index < t.NumField(); index++ {
+		f := t.Field(index)
+		tag, ok := f.Tag.Lookup("funny")
+		if !ok {
+			tag = f.Tag.Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, embedded := range structFields(f.Type) {
+				embedded.index = append([]int{index}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{
+			name:      name,
+			index:     []int{index},
+			omitEmpty: strings.Contains(","+options+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+// goFunc bind go function f by reflection as builtin function name
+func goFunc(name string, f interface{}) (BuiltinFunction, error) {
+	rf := reflect.ValueOf(f)
+	if !rf.IsValid() || rf.Kind() != reflect.Func || rf.IsNil() {
+		return nil, fmt.Errorf("function [%s] must be a go function but got %s", name, Typing(f))
+	}
+	t := rf.Type()
+	withFunny := t.NumIn() > 0 && t.In(0) == funnyPtrType
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("function [%s] must return at most a value and an error", name)
+	}
+	first := 0
+	if withFunny {
+		first = 1
+	}
+	params := t.NumIn() - first
+	return func(fn *Funny, args []Value) Value {
+		if t.IsVariadic() {
+			ackGt(fn, args, params-2)
+		} else {
+			ackEq(fn, args, params)
+		}
+		in := make([]reflect.Value, 0, t.NumIn())
+		if withFunny {
+			in = append(in, reflect.ValueOf(fn))
+		}
+		for index, arg := range args {
+			var pt reflect.Type
+			if t.IsVariadic() && first+index >= t.NumIn()-1 {
+				pt = t.In(t.NumIn() - 1).Elem()
+			} else {
+				pt = t.In(first + index)
+			}
+			pv := reflect.New(pt).Elem()
+			if err := fromValue(arg, pv, ""); err != nil {
+				panic(P(fmt.Sprintf("%s argument %d: %s", name, index+1, err), fn.Current))
+			}
+			in = append(in, pv)
+		}
+		out := rf.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				var fre *FunnyRuntimeError
+				if errors.As(err, &fre) {
+					panic(fre)
+				}
+				panic(&FunnyRuntimeError{
+					Postion: fn.Current,
+					Msg:     err.Error(),
+					Err:     err,
+				})
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return toValue(out[0])
+	}, nil
+}
+
+// RegisterGoFunc register go function f as builtin function name, its arguments are
+// converted by FromValue and its result by ToValue. f may take *Funny as the first
+// parameter, be variadic, and return nothing, a value, an error, or a value and an error.
+// A non-nil error is raised as a FunnyRuntimeError wrapping it
+func (r *Registry) RegisterGoFunc(name string, f interface{}) error {
+	bf, err := goFunc(name, f)
+	if err != nil {
+		return err
+	}
+	return r.Register(name, bf)
+}
+
+// RegisterGoFunc register go function f for this interpreter, like Registry.RegisterGoFunc
+func (i *Funny) RegisterGoFunc(name string, f interface{}) error {
+	return i.Builtins.RegisterGoFunc(name, f)
+}
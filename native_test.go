@@ -1,6 +1,13 @@
 package funny
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func TestTyping(t *testing.T) {
 	d := Typing(&Token{
@@ -12,3 +19,125 @@ func TestTyping(t *testing.T) {
 		t.Log(d)
 	}
 }
+
+type address struct {
+	City string `funny:"city"`
+	Zip  string `json:"zip,omitempty"`
+}
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type person struct {
+	base
+	Name     string            `funny:"name"`
+	Age      uint8             `funny:"age"`
+	Tags     []string          `funny:"tags"`
+	Address  *address          `funny:"address"`
+	Birthday time.Time         `funny:"birthday"`
+	Extra    map[string]string `funny:"extra"`
+	Secret   string            `funny:"-"`
+	score    int
+}
+
+func TestToValue(t *testing.T) {
+	birthday := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	v := ToValue(&person{
+		base:     base{ID: 7},
+		Name:     "funny",
+		Age:      20,
+		Tags:     []string{"a", "b"},
+		Address:  &address{City: "x"},
+		Birthday: birthday,
+		Secret:   "s",
+		score:    1,
+	})
+	assert.Equal(t, map[string]Value{
+		"id":   7,
+		"name": "funny",
+		"age":  20,
+		"tags": []interface{}{"a", "b"},
+		"address": map[string]Value{
+			"city": "x",
+		},
+		"birthday": birthday,
+		"extra":    nil,
+	}, v)
+	assert.Equal(t, 3.5, ToValue(float32(3.5)))
+	assert.Equal(t, []interface{}{1, 2}, ToValue([2]int64{1, 2}))
+	assert.Equal(t, "raw", ToValue([]byte("raw")))
+}
+
+func TestFromValue(t *testing.T) {
+	var p person
+	err := FromValue(map[string]Value{
+		"id":       1,
+		"name":     "funny",
+		"age":      20,
+		"tags":     []interface{}{"a"},
+		"address":  map[string]interface{}{"city": "x", "zip": "1"},
+		"birthday": "2000-01-02T00:00:00Z",
+		"extra":    map[string]Value{"k": "v"},
+		"unknown":  true,
+	}, &p)
+	assert.Nil(t, err)
+	assert.Equal(t, person{
+		base:     base{ID: 1},
+		Name:     "funny",
+		Age:      20,
+		Tags:     []string{"a"},
+		Address:  &address{City: "x", Zip: "1"},
+		Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Extra:    map[string]string{"k": "v"},
+	}, p)
+
+	err = FromValue(map[string]Value{"age": 300}, &p)
+	assert.Equal(t, "can not convert int to uint8 at age", err.Error())
+	err = FromValue(map[string]Value{"tags": []interface{}{"a", 1}}, &p)
+	assert.Equal(t, "can not convert int to string at tags[1]", err.Error())
+
+	var f float64
+	assert.Nil(t, FromValue(2, &f))
+	assert.Equal(t, 2.0, f)
+	assert.NotNil(t, FromValue(2, f))
+}
+
+func TestRegisterGoFunc(t *testing.T) {
+	fn := NewFunny()
+	assert.Nil(t, fn.RegisterGoFunc("greet", func(p person, greetings ...string) string {
+		return strings.Join(greetings, " ") + " " + p.Name
+	}))
+	assert.Nil(t, fn.RegisterGoFunc("people.find", func(fn *Funny, id int) (*person, error) {
+		if id < 0 {
+			return nil, errors.New("id must not be negative")
+		}
+		return &person{base: base{ID: id}, Name: "p"}, nil
+	}))
+	assert.NotNil(t, fn.RegisterGoFunc("bad", 1))
+	assert.NotNil(t, fn.RegisterGoFunc("bad", nil))
+	var nilFunc func() int
+	assert.NotNil(t, fn.RegisterGoFunc("bad", nilFunc))
+	assert.NotNil(t, fn.RegisterGoFunc("bad", func() (int, int) { return 0, 0 }))
+
+	r, err := fn.Run(`
+p = people.find(3)
+return greet(p, 'hello', 'dear')
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello dear p", r)
+
+	r, err = fn.Run("return people.find(2).id")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r)
+
+	_, err = fn.Run("people.find(-1)")
+	assert.Equal(t, "id must not be negative", err.(*FunnyRuntimeError).Msg)
+	assert.NotNil(t, errors.Unwrap(err))
+
+	_, err = fn.Run("people.find('x')")
+	assert.Equal(t, "people.find argument 1: can not convert string to int", err.(*FunnyRuntimeError).Msg)
+
+	_, err = fn.Run("greet()")
+	assert.Equal(t, "greater than 0 arguments required but got 0", err.(*FunnyRuntimeError).Msg)
+}
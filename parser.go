@@ -8,6 +8,31 @@ import (
 	"strings"
 )
 
+const (
+	// LowestPrecedence is lower than the precedence of every binary operator
+	LowestPrecedence = 0
+	// NotPrecedence makes `not a == b` read as `not (a == b)` and `not a and b` as `(not a) and b`
+	NotPrecedence = 2
+)
+
+// Precedences of binary operators, operators with higher precedence bind tighter
+var Precedences = map[string]int{
+	OR:        1,
+	AND:       2,
+	DOUBLE_EQ: 3,
+	NOTEQ:     3,
+	LT:        3,
+	LTE:       3,
+	GT:        3,
+	GTE:       3,
+	IN:        3,
+	NOTIN:     3,
+	PLUS:      4,
+	MINUS:     4,
+	TIMES:     5,
+	DEVIDE:    5,
+}
+
 // Parser the parser
 type Parser struct {
 	Lexer   *Lexer
@@ -16,6 +41,9 @@ type Parser struct {
 	Tokens []Token
 
 	ContentFile string
+
+	// Capabilities the files import can read are limited by it if it is not nil
+	Capabilities *Capabilities
 }
 
 // NewParser create a new parser
@@ -83,6 +111,8 @@ func (p *Parser) ReadStatement() Statement {
 				return p.ReadIF()
 			case FOR:
 				return p.ReadFOR()
+			case TRY:
+				return p.ReadTry(current)
 			case BREAK:
 				return &Break{
 					Position: current.Position,
@@ -111,7 +141,7 @@ func (p *Parser) ReadStatement() Statement {
 		case LParenthese:
 			return p.ReadFunction(current.Data)
 		case DOT:
-			field := &Field{
+			exp := p.ReadPostfix(&Field{
 				Position: current.Position,
 				Variable: Variable{
 					Position: current.Position,
@@ -120,36 +150,20 @@ func (p *Parser) ReadStatement() Statement {
 				},
 				Value: p.ReadField(),
 				Type:  STField,
-			}
+			})
 			if p.Current.Kind == EQ {
 				p.Consume(EQ)
 				return &Assign{
 					Position: current.Position,
-					Target:   field,
+					Target:   exp,
 					Value:    p.ReadExpression(),
 					Type:     STAssign,
 				}
 			}
-			return field
+			return p.readBinary(exp, LowestPrecedence)
 		case LBracket:
-			key := p.Consume(STRING)
-			p.Consume(RBracket)
-			field := &Field{
-				Position: current.Position,
-				Variable: Variable{
-					Position: current.Position,
-					Name:     current.Data,
-					Type:     STVariable,
-				},
-				Value: &Variable{
-					Position: current.Position,
-					Name:     key.Data,
-					Type:     STVariable,
-				},
-				Type: STField,
-			}
-			switch p.Current.Kind {
-			case EQ:
+			field := p.ReadPostfix(p.ReadBracketAccess(current))
+			if p.Current.Kind == EQ {
 				p.Consume(EQ)
 				return &Assign{
 					Position: current.Position,
@@ -157,15 +171,8 @@ func (p *Parser) ReadStatement() Statement {
 					Value:    p.ReadExpression(),
 					Type:     STAssign,
 				}
-			case MINUS, PLUS, TIMES, DEVIDE, LT, LTE, GT, GTE, DOUBLE_EQ:
-				return &BinaryExpression{
-					Position: current.Position,
-					Left:     field,
-					Operator: p.Consume(p.Current.Kind),
-					Right:    p.ReadExpression(),
-					Type:     STBinaryExpression,
-				}
 			}
+			return p.readBinary(field, LowestPrecedence)
 		}
 	case COMMENT:
 		return &Comment{
@@ -252,20 +259,84 @@ func (p *Parser) ReadIF() Statement {
 	return item
 }
 
+// ReadBlock read statements between { and }
+func (p *Parser) ReadBlock() *Block {
+	if p.Current.Kind != LBrace {
+		panic(P(fmt.Sprintf("block expect { but got %s", p.Current.Data), p.Current.Position))
+	}
+	b := &Block{
+		Position: p.Current.Position,
+		Type:     STBlock,
+	}
+	p.Consume(LBrace)
+	for {
+		if p.Current.Kind == RBrace {
+			p.Consume(RBrace)
+			break
+		}
+		if p.Current.Kind == EOF {
+			panic(P("block not closed", p.Current.Position))
+		}
+		b.Statements = append(b.Statements, p.ReadStatement())
+	}
+	return b
+}
+
+// skipNewLines skip new lines before else, catch or finally
+func (p *Parser) skipNewLines() {
+	for p.Current.Kind == NEW_LINE {
+		p.Consume("")
+	}
+}
+
+// ReadTry read try catch finally statement
+func (p *Parser) ReadTry(current Token) Statement {
+	item := &TryStatement{
+		Position: current.Position,
+		Type:     STTryStatement,
+	}
+	item.Body = p.ReadBlock()
+	p.skipNewLines()
+	if p.Current.Kind == NAME && p.Current.Data == CATCH {
+		p.Consume(NAME)
+		if p.Current.Kind == NAME {
+			name := p.Consume(NAME)
+			item.Error = &Variable{
+				Position: name.Position,
+				Name:     name.Data,
+				Type:     STVariable,
+			}
+		}
+		item.Catch = p.ReadBlock()
+		p.skipNewLines()
+	}
+	if p.Current.Kind == NAME && p.Current.Data == FINALLY {
+		p.Consume(NAME)
+		item.Finally = p.ReadBlock()
+	}
+	if item.Catch == nil && item.Finally == nil {
+		panic(P("try must has catch or finally part", p.Current.Position))
+	}
+	return item
+}
+
 // ReadFOR read for statement
 func (p *Parser) ReadFOR() Statement {
-	var item FORStatement
+	item := &FORStatement{
+		Position: p.Current.Position,
+		Type:     STForStatement,
+	}
 	if p.Current.Kind == NAME {
 		index := p.Consume(NAME)
 		item.CurrentIndex = Variable{
-			Position: p.Current.Position,
+			Position: index.Position,
 			Name:     index.Data,
 			Type:     STVariable,
 		}
 		p.Consume(COMMA)
 		val := p.Consume(NAME)
 		item.CurrentItem = &Variable{
-			Position: p.Current.Position,
+			Position: val.Position,
 			Name:     val.Data,
 			Type:     STVariable,
 		}
@@ -273,15 +344,10 @@ func (p *Parser) ReadFOR() Statement {
 			panic(P("for must has in part", p.Current.Position))
 		}
 		p.Consume(NAME)
-		iterable := p.Consume(NAME)
 		item.Iterable = IterableExpression{
 			Position: p.Current.Position,
-			Name: Variable{
-				Position: p.Current.Position,
-				Name:     iterable.Data,
-				Type:     STVariable,
-			},
-			Type: STIterableExpression,
+			Value:    p.ReadExpression(),
+			Type:     STIterableExpression,
 		}
 	} else {
 		item.CurrentIndex = Variable{
@@ -296,7 +362,7 @@ func (p *Parser) ReadFOR() Statement {
 		}
 		item.Iterable = IterableExpression{
 			Position: p.Current.Position,
-			Name: Variable{
+			Value: &Variable{
 				Position: p.Current.Position,
 				Name:     "items",
 				Type:     STVariable,
@@ -304,17 +370,22 @@ func (p *Parser) ReadFOR() Statement {
 			Type: STIterableExpression,
 		}
 	}
+	item.Block.Position = p.Current.Position
+	item.Block.Type = STBlock
 	p.Consume(LBrace)
 	for {
 		if p.Current.Kind == RBrace {
 			p.Consume(RBrace)
 			break
 		}
+		if p.Current.Kind == EOF {
+			panic(P("for statement not closed", p.Current.Position))
+		}
 		sub := p.ReadStatement()
 		item.Block.Statements = append(item.Block.Statements, sub)
 	}
 
-	return &item
+	return item
 }
 
 // ReadFunctionCall read function statement
@@ -363,11 +434,13 @@ func (p *Parser) ReadFunctionCall(name string) Statement {
 		} else {
 			panic(P(fmt.Sprintf("import module path not found %s", fn.Parameters[0].String()), p.Current.Position))
 		}
+		p.checkRead(moduleFileName)
 		importData, err := os.ReadFile(moduleFileName)
 		if err != nil {
 			panic(P(fmt.Sprintf("import module path not found %s", fn.Parameters[0].String()), p.Current.Position))
 		}
 		importParser := NewParser(importData, moduleFileName)
+		importParser.Capabilities = p.Capabilities
 		block, err := importParser.Parse()
 		if err != nil {
 			panic(err)
@@ -387,6 +460,14 @@ func (p *Parser) ReadFunctionCall(name string) Statement {
 	}
 }
 
+// checkRead raise error if the module filename can not be read by the capabilities, like
+// Funny.CheckRead does for readtext
+func (p *Parser) checkRead(filename string) {
+	if c := p.Capabilities; c != nil && !c.allowPath(c.Read, filename) {
+		panic(permissionError(fmt.Sprintf("import file [%s]", filename), p.Current.Position))
+	}
+}
+
 // ReadFunction read function statement
 func (p *Parser) ReadFunction(name string) Statement {
 	pos := p.Current.Position
@@ -421,6 +502,7 @@ func (p *Parser) ReadFunction(name string) Statement {
 			}
 			fn.Body.Statements = append(fn.Body.Statements, sub)
 		}
+		fn.Position = pos
 		return fn
 	}
 	if fn.Name == "import" {
@@ -447,11 +529,13 @@ func (p *Parser) ReadFunction(name string) Statement {
 		} else {
 			panic(P(fmt.Sprintf("import module path not found %s", fn.Parameters[0].String()), p.Current.Position))
 		}
+		p.checkRead(moduleFileName)
 		importData, err := os.ReadFile(moduleFileName)
 		if err != nil {
 			panic(P(fmt.Sprintf("import module path not found %s", fn.Parameters[0].String()), p.Current.Position))
 		}
 		importParser := NewParser(importData, moduleFileName)
+		importParser.Capabilities = p.Capabilities
 		block, err := importParser.Parse()
 		if err != nil {
 			panic(err)
@@ -471,6 +555,33 @@ func (p *Parser) ReadFunction(name string) Statement {
 	}
 }
 
+// ReadFunctionLiteral read anonymous function like fn(a, b) { ... }
+func (p *Parser) ReadFunctionLiteral(current Token) Statement {
+	fn := &Function{
+		Position: current.Position,
+		Type:     STFunction,
+	}
+	p.Consume(LParenthese)
+	for p.Current.Kind != RParenthese {
+		switch p.Current.Kind {
+		case COMMA:
+			p.Consume(COMMA)
+		case NAME:
+			param := p.Consume(NAME)
+			fn.Parameters = append(fn.Parameters, &Variable{
+				Position: param.Position,
+				Name:     param.Data,
+				Type:     STVariable,
+			})
+		default:
+			panic(P(fmt.Sprintf("function parameter must be name but got %s", p.Current.Data), p.Current.Position))
+		}
+	}
+	p.Consume(RParenthese)
+	fn.Body = p.ReadBlock()
+	return fn
+}
+
 // ReadList read list expression
 func (p *Parser) ReadList() Statement {
 	startPosition := p.Current.Position
@@ -505,55 +616,112 @@ func (p *Parser) ReadList() Statement {
 
 // ReadExpression read next expression
 func (p *Parser) ReadExpression() Statement {
+	exp := p.ReadBinaryExpression(LowestPrecedence)
+	if p.Current.Kind == EQ {
+		switch exp.(type) {
+		case *Field, *ListAccess:
+			p.Consume(EQ)
+			return &Assign{
+				Position: exp.GetPosition(),
+				Target:   exp,
+				Value:    p.ReadExpression(),
+				Type:     STAssign,
+			}
+		}
+	}
+	return exp
+}
+
+// ReadBinaryExpression read next expression whose operators bind tighter than precedence
+func (p *Parser) ReadBinaryExpression(precedence int) Statement {
+	return p.readBinary(p.ReadPrimaryExpression(), precedence)
+}
+
+// readBinary read the rest of the expression starting with left whose operators bind tighter
+// than precedence
+func (p *Parser) readBinary(left Statement, precedence int) Statement {
+	for {
+		operator := p.PeekOperator()
+		if operator == "" || Precedences[operator] <= precedence {
+			return left
+		}
+		token := p.ReadOperator(operator)
+		left = &BinaryExpression{
+			Position: left.GetPosition(),
+			Left:     left,
+			Operator: token,
+			Right:    p.ReadBinaryExpression(Precedences[operator]),
+			Type:     STBinaryExpression,
+		}
+	}
+}
+
+// PeekOperator get the binary operator at current position, empty if there is none
+func (p *Parser) PeekOperator() string {
+	if p.Current.Kind != NAME {
+		if _, ok := Precedences[p.Current.Kind]; ok {
+			return p.Current.Kind
+		}
+		return ""
+	}
+	switch p.Current.Data {
+	case AND, OR, IN:
+		return p.Current.Data
+	case NOT:
+		if next := p.Peek(); next.Kind == NAME && next.Data == IN {
+			return NOTIN
+		}
+	}
+	return ""
+}
+
+// ReadOperator consume the operator returned by PeekOperator
+func (p *Parser) ReadOperator(operator string) Token {
+	token := p.Consume("")
+	if operator == NOTIN {
+		in := p.Consume(NAME)
+		token.Data = NOTIN
+		token.Position.Length = in.Position.Col + in.Position.Length - token.Position.Col
+	}
+	return token
+}
+
+// Peek get the token after current without consuming anything
+func (p *Parser) Peek() Token {
+	saved := *p.Lexer
+	token := p.Lexer.Next()
+	*p.Lexer = saved
+	return token
+}
+
+// ReadPrimaryExpression read operand with its chained access like a.b[0].c
+func (p *Parser) ReadPrimaryExpression() Statement {
+	return p.ReadPostfix(p.ReadOperand())
+}
+
+// ReadOperand read literals, variables, calls, fields and sub expressions
+func (p *Parser) ReadOperand() Statement {
 	current := p.Consume("")
 	switch current.Kind {
 	case NAME:
-		if current.Data == IN {
-			return &BinaryExpression{
-				Position: current.Position,
-				Left: &Variable{
-					Position: current.Position,
-					Name:     current.Data,
-					Type:     STVariable,
-				},
-				Operator: current,
-				Right:    p.ReadExpression(),
-				Type:     STBinaryExpression,
+		if current.Data == NOT {
+			return &UnaryExpression{
+				Position:   current.Position,
+				Operator:   current,
+				Expression: p.ReadBinaryExpression(NotPrecedence),
+				Type:       STUnaryExpression,
 			}
 		}
+		if current.Data == FN && p.Current.Kind == LParenthese {
+			return p.ReadFunctionLiteral(current)
+		}
 		switch p.Current.Kind {
-		case PLUS, MINUS, TIMES, DEVIDE, LT, LTE, GT, GTE, DOUBLE_EQ, NAME:
-			return &BinaryExpression{
-				Position: current.Position,
-				Left: &Variable{
-					Position: current.Position,
-					Name:     current.Data,
-					Type:     STVariable,
-				},
-				Operator: p.Consume(p.Current.Kind),
-				Right:    p.ReadExpression(),
-				Type:     STBinaryExpression,
-			}
 		case LParenthese:
 			p.Consume(LParenthese)
-			fn1 := p.ReadFunctionCall(current.Data)
-			switch item := fn1.(type) {
-			case *FunctionCall:
-				switch p.Current.Kind {
-				case MINUS, PLUS, TIMES, DEVIDE:
-					return &BinaryExpression{
-						Position: current.Position,
-						Left:     item,
-						Operator: p.Consume(p.Current.Kind),
-						Right:    p.ReadExpression(),
-						Type:     STBinaryExpression,
-					}
-				}
-			}
-			return fn1
+			return p.ReadFunctionCall(current.Data)
 		case DOT:
 			p.Consume(DOT)
-			field := &Field{
+			return &Field{
 				Position: current.Position,
 				Variable: Variable{
 					Position: current.Position,
@@ -563,194 +731,76 @@ func (p *Parser) ReadExpression() Statement {
 				Value: p.ReadField(),
 				Type:  STField,
 			}
-			switch p.Current.Kind {
-			case EQ:
-				p.Consume(EQ)
-				return &Assign{
-					Position: current.Position,
-					Target:   field,
-					Value:    p.ReadExpression(),
-					Type:     STAssign,
-				}
-			case MINUS, PLUS, TIMES, DEVIDE, LT, LTE, GT, GTE, DOUBLE_EQ:
-				return &BinaryExpression{
-					Position: current.Position,
-					Left:     field,
-					Operator: p.Consume(p.Current.Kind),
-					Right:    p.ReadExpression(),
-					Type:     STBinaryExpression,
-				}
-			}
-			return field
 		case LBracket:
 			p.Consume(LBracket)
-			var exp Statement
-			if p.Current.Kind == NAME {
-				// Field access
-				key := p.Consume("")
-				p.Consume(RBracket)
-				exp = &Field{
-					Position: current.Position,
-					Variable: Variable{
-						Position: current.Position,
-						Name:     current.Data,
-						Type:     STVariable,
-					},
-					Value: &Variable{
-						Name:     key.Data,
-						Type:     STVariable,
-						Position: key.Position,
-					},
-					Type: STField,
-				}
-			} else if p.Current.Kind == STRING {
-				// Field access
-				key := p.Consume("")
-				p.Consume(RBracket)
-				exp = &Field{
-					Position: current.Position,
-					Variable: Variable{
-						Position: current.Position,
-						Name:     current.Data,
-						Type:     STVariable,
-					},
-					Value: &StringExpression{
-						Type:     STStringExpression,
-						Value:    key.Data,
-						Position: key.Position,
-					},
-					Type: STField,
-				}
-			} else if p.Current.Kind == INT {
-				token := p.Consume(INT)
-				indexStr := token.Data
-				index, err := strconv.Atoi(indexStr)
-				if err != nil {
-					panic(P("Bad list index ", token.Position))
-				}
-				exp = &ListAccess{
-					Position: current.Position,
-					List: Variable{
-						Position: current.Position,
-						Name:     current.Data,
-						Type:     STVariable,
-					},
-					Index: index,
-					Type:  STListAccess,
-				}
-				p.Consume(RBracket)
-			} else {
-				panic(P(fmt.Sprintf("Unknow Kind Reading Field %s", p.Current.Kind), p.Current.Position))
-			}
-
-			switch p.Current.Kind {
-			case EQ:
-				p.Consume(EQ)
-				return &Assign{
-					Position: current.Position,
-					Target:   exp,
-					Value:    p.ReadExpression(),
-					Type:     STAssign,
-				}
-			case MINUS, PLUS, TIMES, DEVIDE, LT, LTE, GT, GTE, DOUBLE_EQ:
-				return &BinaryExpression{
-					Position: current.Position,
-					Left:     exp,
-					Operator: p.Consume(p.Current.Kind),
-					Right:    p.ReadExpression(),
-					Type:     STBinaryExpression,
-				}
-			default:
-				return exp
-			}
-
-		default:
-			if current.Data == "true" {
-				return &Boolen{
-					Position: current.Position,
-					Value:    true,
-					Type:     STBoolean,
-				}
-			}
-			if current.Data == "false" {
-				return &Boolen{
-					Position: current.Position,
-					Value:    false,
-					Type:     STBoolean,
-				}
-			}
-			switch p.Current.Kind {
-			case PLUS:
-			case MINUS:
-				return p.ReadExpression()
+			return p.ReadBracketAccess(current)
+		}
+		switch current.Data {
+		case TRUE:
+			return &Boolen{
+				Position: current.Position,
+				Value:    true,
+				Type:     STBoolean,
 			}
-			return &Variable{
+		case FALSE:
+			return &Boolen{
 				Position: current.Position,
-				Name:     current.Data,
-				Type:     STVariable,
+				Value:    false,
+				Type:     STBoolean,
 			}
 		}
+		return &Variable{
+			Position: current.Position,
+			Name:     current.Data,
+			Type:     STVariable,
+		}
 	case PLUS:
-		return p.ReadExpression()
+		return p.ReadPrimaryExpression()
+	case BANG, MINUS:
+		return &UnaryExpression{
+			Position:   current.Position,
+			Operator:   current,
+			Expression: p.ReadPrimaryExpression(),
+			Type:       STUnaryExpression,
+		}
 	case INT:
-		value, _ := strconv.Atoi(current.Data)
-		switch p.Current.Kind {
-		case MINUS, PLUS, TIMES, DEVIDE, LT, LTE, GT, GTE, DOUBLE_EQ, NAME:
-			return &BinaryExpression{
-				Position: current.Position,
-				Left: &Literal{
-					Position: current.Position,
-					Value:    value,
-					Type:     STLiteral,
-				},
-				Operator: p.Consume(p.Current.Kind),
-				Right:    p.ReadExpression(),
-				Type:     STBinaryExpression,
-			}
+		value, err := strconv.Atoi(current.Data)
+		if err != nil {
+			panic(P(fmt.Sprintf("Bad int literal %s", current.Data), current.Position))
 		}
 		return &Literal{
 			Position: current.Position,
 			Value:    value,
 			Type:     STLiteral,
 		}
-	case STRING:
-		switch p.Current.Kind {
-		case PLUS, MINUS:
-			return &BinaryExpression{
-				Position: current.Position,
-				Left: &Literal{
-					Position: current.Position,
-					Value:    current.Data,
-					Type:     STLiteral,
-				},
-				Operator: p.Consume(p.Current.Kind),
-				Right:    p.ReadExpression(),
-				Type:     STBinaryExpression,
-			}
+	case FLOAT:
+		value, err := strconv.ParseFloat(current.Data, 64)
+		if err != nil {
+			panic(P(fmt.Sprintf("Bad float literal %s", current.Data), current.Position))
+		}
+		return &Literal{
+			Position: current.Position,
+			Value:    value,
+			Type:     STLiteral,
 		}
+	case STRING:
 		return &Literal{
 			Position: current.Position,
 			Value:    current.Data,
 			Type:     STLiteral,
 		}
+	case TEMPLATE:
+		return p.ReadTemplate(current)
 	case LParenthese:
-		p.Consume(LParenthese)
 		exp := &SubExpression{
-			Position:   p.Current.Position,
+			Position:   current.Position,
 			Type:       STSubExpression,
 			Expression: p.ReadExpression(),
 		}
-		p.Consume(RParenthese)
-		switch p.Current.Kind {
-		case MINUS, PLUS, TIMES, DEVIDE:
-			return &BinaryExpression{
-				Position: p.Current.Position,
-				Type:     STBinaryExpression,
-				Left:     exp,
-				Operator: p.Consume(""),
-				Right:    p.ReadExpression(),
-			}
+		if p.Current.Kind != RParenthese {
+			panic(P(fmt.Sprintf("sub expression expect ) but got %s", p.Current.Data), p.Current.Position))
 		}
+		p.Consume(RParenthese)
 		return exp
 	case LBrace:
 		return p.ReadDict()
@@ -760,6 +810,151 @@ func (p *Parser) ReadExpression() Statement {
 	panic(P(fmt.Sprintf("Unknow Expression Data: %s", current.Data), current.Position))
 }
 
+// ReadTemplate split template string like "hello ${name}" into literals and expressions
+func (p *Parser) ReadTemplate(current Token) Statement {
+	template := &StringTemplate{
+		Position: current.Position,
+		Type:     STStringTemplate,
+	}
+	data := current.Data
+	literal := 0
+	addLiteral := func(end int) {
+		if end > literal {
+			template.Parts = append(template.Parts, &Literal{
+				Position: current.Position,
+				Value:    Unescape(data[literal:end]),
+				Type:     STLiteral,
+			})
+		}
+	}
+	for index := 0; index < len(data); index++ {
+		if data[index] == '\\' {
+			index++
+			continue
+		}
+		if data[index] != '$' || index+1 == len(data) || data[index+1] != '{' {
+			continue
+		}
+		addLiteral(index)
+		end := InterpolationEnd([]byte(data), index)
+		template.Parts = append(template.Parts, p.readInterpolation(current, data[index+2:end-1]))
+		index = end - 1
+		literal = end
+	}
+	addLiteral(len(data))
+	return template
+}
+
+// readInterpolation parse the expression inside ${}
+func (p *Parser) readInterpolation(current Token, code string) Statement {
+	sub := NewParser([]byte(code), p.ContentFile)
+	sub.Capabilities = p.Capabilities
+	sub.Lexer.CurrentPos.Line = current.Position.Line
+	sub.Lexer.CurrentPos.Col = current.Position.Col
+	sub.Consume("")
+	if sub.Current.Kind == EOF {
+		panic(P("empty string interpolation", current.Position))
+	}
+	exp := sub.ReadExpression()
+	if sub.Current.Kind != EOF {
+		panic(P(fmt.Sprintf("invalid string interpolation ${%s}", code), current.Position))
+	}
+	return exp
+}
+
+// ReadBracketAccess read field, list access or slice like a['key'], a[key], a[0] or a[1:3]
+func (p *Parser) ReadBracketAccess(current Token) Statement {
+	if p.Current.Kind == STRING && p.Peek().Kind == RBracket {
+		key := p.Consume(STRING)
+		p.Consume(RBracket)
+		return &Field{
+			Position: current.Position,
+			Variable: Variable{
+				Position: current.Position,
+				Name:     current.Data,
+				Type:     STVariable,
+			},
+			Value: &StringExpression{
+				Type:     STStringExpression,
+				Value:    key.Data,
+				Position: key.Position,
+			},
+			Type: STField,
+		}
+	}
+	return p.ReadIndex(&Variable{
+		Position: current.Position,
+		Name:     current.Data,
+		Type:     STVariable,
+	})
+}
+
+// ReadIndex read index or slice of list after [
+func (p *Parser) ReadIndex(list Statement) Statement {
+	var index Statement
+	if p.Current.Kind != COLON {
+		index = p.ReadExpression()
+	}
+	if p.Current.Kind == COLON {
+		p.Consume(COLON)
+		slice := &Slice{
+			Position: list.GetPosition(),
+			List:     list,
+			Start:    index,
+			Type:     STSlice,
+		}
+		if p.Current.Kind != RBracket {
+			slice.End = p.ReadExpression()
+		}
+		p.readRBracket()
+		return slice
+	}
+	p.readRBracket()
+	return &ListAccess{
+		Position: list.GetPosition(),
+		List:     list,
+		Index:    index,
+		Type:     STListAccess,
+	}
+}
+
+// readRBracket expect ] closes the index or slice
+func (p *Parser) readRBracket() {
+	if p.Current.Kind != RBracket {
+		panic(P(fmt.Sprintf("index expect ] but got %s", p.Current.Data), p.Current.Position))
+	}
+	p.Consume(RBracket)
+}
+
+// ReadPostfix read chained index, slice and field access after expression
+func (p *Parser) ReadPostfix(exp Statement) Statement {
+	for {
+		switch p.Current.Kind {
+		case LBracket:
+			p.Consume(LBracket)
+			exp = p.ReadIndex(exp)
+		case DOT:
+			p.Consume(DOT)
+			if p.Current.Kind != NAME {
+				panic(P(fmt.Sprintf("field expect name but got %s", p.Current.Data), p.Current.Position))
+			}
+			name := p.Consume(NAME)
+			exp = &ListAccess{
+				Position: exp.GetPosition(),
+				List:     exp,
+				Index: &StringExpression{
+					Position: name.Position,
+					Value:    name.Data,
+					Type:     STStringExpression,
+				},
+				Type: STListAccess,
+			}
+		default:
+			return exp
+		}
+	}
+}
+
 // ReadDict read dict expression
 func (p *Parser) ReadDict() Statement {
 	b := &Block{
@@ -796,9 +991,9 @@ func (p *Parser) ReadField() Statement {
 		p.Consume(LParenthese)
 		return p.ReadFunction(name.Data)
 	}
-	return &Variable{
-		Position: p.Current.Position,
-		Name:     name.Data,
-		Type:     STVariable,
+	return &StringExpression{
+		Position: name.Position,
+		Value:    name.Data,
+		Type:     STStringExpression,
 	}
 }
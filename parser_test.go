@@ -352,3 +352,165 @@ func TestParseIfElseNewLine(t *testing.T) {
 	}
 	fmt.Println(string(echoJson))
 }
+
+func TestParseForStatement(t *testing.T) {
+	parser := NewParser([]byte(`
+for index, item in [1, 2] {
+  if item == 1 {
+    continue
+  }
+  break
+}
+`), "")
+	items, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	loop, ok := items.Statements[1].(*FORStatement)
+	assert.True(t, ok)
+	assert.Equal(t, "index", loop.CurrentIndex.Name)
+	assert.Equal(t, "item", loop.CurrentItem.(*Variable).Name)
+	assert.Equal(t, "[1, 2]", loop.Iterable.String())
+}
+
+func TestParseFunctionLiteral(t *testing.T) {
+	parser := NewParser([]byte(`
+double = fn(x) { return x * 2 }
+`), "")
+	items, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	assign, ok := items.Statements[1].(*Assign)
+	assert.True(t, ok)
+	fn, ok := assign.Value.(*Function)
+	assert.True(t, ok)
+	assert.Equal(t, "", fn.Name)
+	assert.Equal(t, 1, len(fn.Parameters))
+	assert.Equal(t, "fn(x)", fn.SignatureString())
+}
+
+// groupExpression render binary expressions with explicit parentheses
+func groupExpression(s Statement) string {
+	switch v := s.(type) {
+	case *BinaryExpression:
+		return fmt.Sprintf("(%s %s %s)", groupExpression(v.Left), v.Operator.Data, groupExpression(v.Right))
+	case *SubExpression:
+		return groupExpression(v.Expression)
+	case *UnaryExpression:
+		if v.Operator.Kind == NAME {
+			return fmt.Sprintf("%s %s", v.Operator.Data, groupExpression(v.Expression))
+		}
+		return fmt.Sprintf("%s%s", v.Operator.Data, groupExpression(v.Expression))
+	}
+	return s.String()
+}
+
+var precedenceCases = []struct {
+	code     string
+	expected string
+}{
+	{"10 - 2 - 3", "((10 - 2) - 3)"},
+	{"1 + 2 * 3", "(1 + (2 * 3))"},
+	{"1 * 2 + 3", "((1 * 2) + 3)"},
+	{"8 / 4 / 2", "((8 / 4) / 2)"},
+	{"(1 + 2) * 3", "((1 + 2) * 3)"},
+	{"2 * (3 + (4 - 1))", "(2 * (3 + (4 - 1)))"},
+	{"a + 1 > b * 2", "((a + 1) > (b * 2))"},
+	{"a == 1 and b != 2", "((a == 1) and (b != 2))"},
+	{"a or b and c", "(a or (b and c))"},
+	{"a and b or c and d", "((a and b) or (c and d))"},
+	{"a + 1 in [1, 2]", "((a + 1) in [1, 2])"},
+	{"a not in [1, 2] or b", "((a not in [1, 2]) or b)"},
+	{"len(a) - 1 >= 2", "((len(a) - 1) >= 2)"},
+	{"p.age * 2 < 30", "((p.age * 2) < 30)"},
+	{"xs[0] + f(1, 2 * 3)", "(xs[0] + f(1, 2 * 3))"},
+	{"not a == b", "not (a == b)"},
+	{"not a and b", "(not a and b)"},
+	{"!a == b", "(!a == b)"},
+	{"a or !b", "(a or !b)"},
+}
+
+func TestParseExpressionPrecedence(t *testing.T) {
+	for _, item := range precedenceCases {
+		parser := NewParser([]byte(item.code), "")
+		parser.Consume("")
+		exp := parser.ReadExpression()
+		assert.Equal(t, EOF, parser.Current.Kind, item.code)
+		assert.Equal(t, item.expected, groupExpression(exp), item.code)
+	}
+}
+
+func TestParseListAccess(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected string
+	}{
+		{"a = xs[i + 1]", "xs[i + 1]"},
+		{"a = xs[-1]", "xs[-1]"},
+		{"a = xs[1:3]", "xs[1:3]"},
+		{"a = xs[:-1]", "xs[:-1]"},
+		{"a = xs[1:]", "xs[1:]"},
+		{"a = resp.data[0].id", "resp.data[0].id"},
+		{"a = f(1)[0]", "f(1)[0]"},
+	}
+	for _, c := range cases {
+		parser := NewParser([]byte(c.code), "")
+		items, err := parser.Parse()
+		if err != nil {
+			panic(err)
+		}
+		assign := items.Statements[0].(*Assign)
+		assert.Equal(t, c.expected, groupExpression(assign.Value), c.code)
+	}
+}
+
+func TestParseAccessStatement(t *testing.T) {
+	cases := []struct {
+		code     string
+		expected string
+	}{
+		{"xs[0] - 1 - 2", "((xs[0] - 1) - 2)"},
+		{"xs[0] + 1 * 2", "(xs[0] + (1 * 2))"},
+		{"xs[0] == 1 and ok", "((xs[0] == 1) and ok)"},
+		{"d.a - 1 - 2", "((d.a - 1) - 2)"},
+	}
+	for _, c := range cases {
+		parser := NewParser([]byte(c.code), "")
+		items, err := parser.Parse()
+		if err != nil {
+			panic(err)
+		}
+		assert.Equal(t, c.expected, groupExpression(items.Statements[0]), c.code)
+	}
+}
+
+func TestParseStringTemplate(t *testing.T) {
+	parser := NewParser([]byte(`a = "hello ${name}, ${d['k'] + 1}!"`), "")
+	items, err := parser.Parse()
+	if err != nil {
+		panic(err)
+	}
+	template, ok := items.Statements[0].(*Assign).Value.(*StringTemplate)
+	assert.True(t, ok)
+	assert.Equal(t, 5, len(template.Parts))
+	assert.Equal(t, `"hello ${name}, ${d.k + 1}!"`, template.String())
+}
+
+func TestParseStringLiteralString(t *testing.T) {
+	cases := []string{
+		`'it\'s'`,
+		`'a\tb'`,
+		`'\d+'`,
+		`'back\\'`,
+		"'''\nselect 'x'\n'''",
+	}
+	for _, code := range cases {
+		parser := NewParser([]byte("a = "+code), "")
+		items, err := parser.Parse()
+		if err != nil {
+			panic(err)
+		}
+		assert.Equal(t, code, items.Statements[0].(*Assign).Value.String())
+	}
+}
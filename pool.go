@@ -0,0 +1,91 @@
+package funny
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// PoolResult the result of running one file by Pool
+type PoolResult struct {
+	File     string
+	Value    Value
+	Err      error
+	Duration time.Duration
+}
+
+// Pool runs funny files on goroutines with bounded parallelism, every file is run by its own
+// interpreter so they never see the variables or functions of each other
+type Pool struct {
+	// Size the most files run at the same time, runtime.NumCPU() if it is not positive
+	Size int
+	// New create the interpreter running file, NewFunny() is used if it is nil
+	New func(file string) *Funny
+}
+
+// NewPool create a pool runs at most size files at the same time by interpreters
+// created with options
+func NewPool(size int, options ...Option) *Pool {
+	return &Pool{
+		Size: size,
+		New: func(file string) *Funny {
+			return NewFunny(options...)
+		},
+	}
+}
+
+// Run run every file and return the results in the same order as files
+func (p *Pool) Run(files ...string) []PoolResult {
+	return p.RunContext(context.Background(), files...)
+}
+
+// RunContext run every file under ctx like Run, the files not started yet when ctx is done
+// fail with the error of ctx
+func (p *Pool) RunContext(ctx context.Context, files ...string) []PoolResult {
+	size := p.Size
+	if size <= 0 {
+		size = runtime.NumCPU()
+	}
+	results := make([]PoolResult, len(files))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < size && worker < len(files); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = p.runFile(ctx, files[index])
+			}
+		}()
+	}
+	for index := range files {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func (p *Pool) runFile(ctx context.Context, file string) PoolResult {
+	if err := ctx.Err(); err != nil {
+		return PoolResult{
+			File: file,
+			Err:  err,
+		}
+	}
+	var fn *Funny
+	if p.New != nil {
+		fn = p.New(file)
+	} else {
+		fn = NewFunny()
+	}
+	start := time.Now()
+	value, err := fn.RunFileContext(ctx, file)
+	return PoolResult{
+		File:     file,
+		Value:    value,
+		Err:      err,
+		Duration: time.Since(start),
+	}
+}
@@ -0,0 +1,122 @@
+package funny
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolRun(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for index := 0; index < 20; index++ {
+		file := path.Join(dir, fmt.Sprintf("script%d.funny", index))
+		code := fmt.Sprintf(`
+n = %d
+total = 0
+for i, item in [1, 2, 3] {
+  total = total + item * n
+}
+return total
+`, index)
+		if index%5 == 0 {
+			code = fmt.Sprintf("throw('failed %d')\n", index)
+		}
+		assert.Nil(t, os.WriteFile(file, []byte(code), 0o644))
+		files = append(files, file)
+	}
+	files = append(files, path.Join(dir, "missing.funny"))
+
+	results := NewPool(4).Run(files...)
+	assert.Equal(t, len(files), len(results))
+	for index, result := range results[:20] {
+		assert.Equal(t, files[index], result.File)
+		if index%5 == 0 {
+			assert.Equal(t, fmt.Sprintf("failed %d", index), result.Err.(*FunnyRuntimeError).Msg)
+			continue
+		}
+		assert.Nil(t, result.Err)
+		assert.Equal(t, index*6, result.Value)
+	}
+	assert.True(t, os.IsNotExist(results[20].Err))
+	assert.Equal(t, 0, len(NewPool(0).Run()))
+}
+
+func TestPoolInterpretersIndependent(t *testing.T) {
+	dir := t.TempDir()
+	file := path.Join(dir, "script.funny")
+	assert.Nil(t, os.WriteFile(file, []byte("return [id(), shared]\n"), 0o644))
+
+	var mu sync.Mutex
+	count := 0
+	pool := &Pool{
+		Size: 8,
+		New: func(file string) *Funny {
+			mu.Lock()
+			count++
+			id := count
+			mu.Unlock()
+			fn := NewFunnyWithScope(Scope{
+				"shared": id,
+			})
+			assert.Nil(t, fn.RegisterFunction("id", func(fn *Funny, args []Value) Value {
+				return id
+			}))
+			return fn
+		},
+	}
+	files := make([]string, 32)
+	for index := range files {
+		files[index] = file
+	}
+	seen := map[int]bool{}
+	for _, result := range pool.Run(files...) {
+		assert.Nil(t, result.Err)
+		ls := result.Value.([]interface{})
+		assert.Equal(t, ls[0], ls[1])
+		seen[ls[0].(int)] = true
+	}
+	assert.Equal(t, 32, len(seen))
+	_, ok := FUNCTIONS["id"]
+	assert.False(t, ok)
+}
+
+// TestConcurrentInterpreters is meant to be run with -race, the interpreters share the
+// parsed program, the builtins and the registry they are cloned from
+func TestConcurrentInterpreters(t *testing.T) {
+	block, err := NewParser([]byte(`
+fib(n) {
+  if n < 2 {
+    return n
+  }
+  return fib(n - 1) + fib(n - 2)
+}
+words = map(['a', 'b', 'c'], fn(w) { return w + w })
+return [fib(10), words[2], double(4)]
+`), "").Parse()
+	assert.Nil(t, err)
+	base := NewRegistry()
+	assert.Nil(t, base.Register("double", double))
+
+	var wg sync.WaitGroup
+	results := make([]Value, 16)
+	errs := make([]error, 16)
+	for index := range results {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			fn := NewFunny(WithRegistry(base.Clone()))
+			assert.Nil(t, fn.RegisterFunction(fmt.Sprintf("f%d", index), double))
+			results[index], errs[index] = fn.Run(Program{Statements: block})
+		}(index)
+	}
+	wg.Wait()
+	for index := range results {
+		assert.Nil(t, errs[index])
+		assert.Equal(t, []interface{}{55, "cc", 8}, results[index])
+	}
+}
@@ -0,0 +1,212 @@
+package funny
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BUILTIN_GROUPS the named subsets of FUNCTIONS which can be given to WithBuiltins
+var BUILTIN_GROUPS = map[string][]string{
+	"core":     {"echo", "echoln", "readline", "input", "assert", "assertEq", "assertNe", "assertContains", "assertMatch", "assertType", "assertThrows", "assertJsonSubset", "throw", "len", "max", "min", "typeof", "str", "int", "float", "format", "dumpruntimes", "now", "uuid"},
+	"strings":  {"strjoin", "strsplit", "regexMatch", "regexMapMatch", "regexMapValue"},
+	"lists":    {"map", "filter", "reduce", "sort", "find", "any", "all", "groupby", "uniq", "append", "pop", "insert", "remove"},
+	"encoding": {"b64en", "b64de", "md5", "jwten", "jwtde"},
+	"io":       {"readtext", "writetext", "readjson", "writejson"},
+	"net":      {"httpreq", "http.request"},
+	"sql":      {"sqlquery", "sqlexec", "sqlexecfile"},
+	"os":       {"env", "sh"},
+}
+
+// Registry the builtin functions an interpreter can call. The registries created by
+// NewRegistry share FUNCTIONS until they register or remove a function, so changing one
+// never affects the others. Like maps, a registry must not be changed while it is used
+// on other goroutines. Names are identifiers, or namespace and identifier joined by dot
+// like http.get, which are called as http.get() by scripts
+type Registry struct {
+	functions map[string]BuiltinFunction
+	// shared the functions are shared with other registries and must be copied before writing
+	shared bool
+}
+
+// NewRegistry create a registry of all the builtin functions in FUNCTIONS
+func NewRegistry() *Registry {
+	return &Registry{
+		functions: FUNCTIONS,
+		shared:    true,
+	}
+}
+
+// EmptyRegistry create a registry without any function
+func EmptyRegistry() *Registry {
+	return &Registry{
+		functions: make(map[string]BuiltinFunction),
+	}
+}
+
+// Get find the function named name
+func (r *Registry) Get(name string) (BuiltinFunction, bool) {
+	fn, ok := r.functions[name]
+	return fn, ok
+}
+
+// IsNamespace whether some function is registered in namespace ns
+func (r *Registry) IsNamespace(ns string) bool {
+	prefix := ns + "."
+	for name := range r.functions {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names the names of all functions in sorted order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.functions))
+	for name := range r.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register add function fn named name, which must not exist yet
+func (r *Registry) Register(name string, fn BuiltinFunction) error {
+	if !validFunctionName(name) {
+		return fmt.Errorf("invalid function name [%s]", name)
+	}
+	if _, exists := r.functions[name]; exists {
+		return fmt.Errorf("function [%s] already exists", name)
+	}
+	r.write()
+	r.functions[name] = fn
+	return nil
+}
+
+// RegisterNamespace add every function of functions in namespace ns, like http.get for get
+func (r *Registry) RegisterNamespace(ns string, functions map[string]BuiltinFunction) error {
+	for name, fn := range functions {
+		if err := r.Register(ns+"."+name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove delete the function named name if it exists
+func (r *Registry) Remove(name string) {
+	if _, exists := r.functions[name]; !exists {
+		return
+	}
+	r.write()
+	delete(r.functions, name)
+}
+
+// Clone create a registry with the same functions. It only reads r, so registries can be
+// cloned from the same one on different goroutines
+func (r *Registry) Clone() *Registry {
+	if r.shared {
+		return &Registry{
+			functions: r.functions,
+			shared:    true,
+		}
+	}
+	return &Registry{
+		functions: r.copyFunctions(),
+	}
+}
+
+// Subset create a registry with only the functions of names. A name can be a function,
+// a namespace for all functions in it, or a group of BUILTIN_GROUPS
+func (r *Registry) Subset(names ...string) (*Registry, error) {
+	sub := EmptyRegistry()
+	for _, name := range names {
+		if fn, ok := r.functions[name]; ok {
+			sub.functions[name] = fn
+			continue
+		}
+		if group, ok := BUILTIN_GROUPS[name]; ok {
+			for _, item := range group {
+				if fn, ok := r.functions[item]; ok {
+					sub.functions[item] = fn
+				}
+			}
+			continue
+		}
+		if r.IsNamespace(name) {
+			for item, fn := range r.functions {
+				if strings.HasPrefix(item, name+".") {
+					sub.functions[item] = fn
+				}
+			}
+			continue
+		}
+		return nil, fmt.Errorf("function [%s] not defined", name)
+	}
+	return sub, nil
+}
+
+// write copy the functions before writing if they are shared
+func (r *Registry) write() {
+	if !r.shared {
+		return
+	}
+	r.functions = r.copyFunctions()
+	r.shared = false
+}
+
+func (r *Registry) copyFunctions() map[string]BuiltinFunction {
+	functions := make(map[string]BuiltinFunction, len(r.functions)+1)
+	for name, fn := range r.functions {
+		functions[name] = fn
+	}
+	return functions
+}
+
+// validFunctionName whether name is an identifier, optionally prefixed by a namespace and dot
+func validFunctionName(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+		for index, ch := range part {
+			if !(ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || index > 0 && ch >= '0' && ch <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Option configures an interpreter created by NewFunny
+type Option func(*Funny)
+
+// WithRegistry use registry r for the builtin functions, give each interpreter its own clone
+// if they run on different goroutines and register functions
+func WithRegistry(r *Registry) Option {
+	return func(fn *Funny) {
+		fn.Builtins = r
+	}
+}
+
+// WithoutBuiltins start without any builtin function
+func WithoutBuiltins() Option {
+	return WithRegistry(EmptyRegistry())
+}
+
+// WithBuiltins start with only the builtin functions of names, see Registry.Subset.
+// It panics if some name is not defined
+func WithBuiltins(names ...string) Option {
+	return func(fn *Funny) {
+		sub, err := NewRegistry().Subset(names...)
+		if err != nil {
+			panic(err)
+		}
+		fn.Builtins = sub
+	}
+}
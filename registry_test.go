@@ -0,0 +1,101 @@
+package funny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func double(fn *Funny, args []Value) Value {
+	return args[0].(int) * 2
+}
+
+func TestRegistryIsolation(t *testing.T) {
+	a := NewFunny()
+	b := NewFunny()
+	assert.Nil(t, a.RegisterFunction("double", double))
+	assert.NotNil(t, a.RegisterFunction("double", double))
+
+	r, err := a.Run("return double(2)")
+	assert.Nil(t, err)
+	assert.Equal(t, 4, r)
+
+	_, err = b.Run("return double(2)")
+	assert.Equal(t, "function [double] not defined", err.(*FunnyRuntimeError).Msg)
+	_, ok := FUNCTIONS["double"]
+	assert.False(t, ok)
+
+	c := &Funny{
+		Vars:     []Scope{{}},
+		Builtins: a.Builtins.Clone(),
+	}
+	a.Builtins.Remove("double")
+	_, ok = a.Builtins.Get("double")
+	assert.False(t, ok)
+	_, ok = c.Builtins.Get("double")
+	assert.True(t, ok)
+}
+
+func TestRegistryNamespace(t *testing.T) {
+	fn := NewFunny()
+	assert.Nil(t, fn.RegisterFunction("math.double", double))
+	assert.Nil(t, fn.Builtins.RegisterNamespace("calc", map[string]BuiltinFunction{
+		"twice": double,
+	}))
+	r, err := fn.Run(`
+math2 = {
+  double(n) {
+    return n
+  }
+}
+return [math.double(2), calc.twice(3), math2.double(2)]
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{4, 6, 2}, r)
+	assert.True(t, fn.Builtins.IsNamespace("math"))
+	assert.False(t, fn.Builtins.IsNamespace("mat"))
+
+	for _, name := range []string{"", "a.", ".a", "a.b.c", "1a", "a-b"} {
+		assert.NotNil(t, fn.RegisterFunction(name, double), name)
+	}
+}
+
+func TestRegistrySubset(t *testing.T) {
+	fn := NewFunny(WithBuiltins("lists", "len"))
+	r, err := fn.Run("return len(map([1, 2], fn(x) { return x }))")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r)
+	_, err = fn.Run("sh('ls')")
+	assert.Equal(t, "function [sh] not defined", err.(*FunnyRuntimeError).Msg)
+
+	fn = NewFunny(WithoutBuiltins())
+	assert.Equal(t, 0, len(fn.Builtins.Names()))
+	_, err = fn.Run("echo(1)")
+	assert.NotNil(t, err)
+
+	_, err = NewRegistry().Subset("notDefined")
+	assert.NotNil(t, err)
+
+	registry := EmptyRegistry()
+	assert.Nil(t, registry.RegisterNamespace("db", map[string]BuiltinFunction{
+		"query": double,
+		"exec":  double,
+	}))
+	sub, err := registry.Subset("db")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"db.exec", "db.query"}, sub.Names())
+}
+
+func TestRegistryGroups(t *testing.T) {
+	grouped := map[string]bool{}
+	for _, names := range BUILTIN_GROUPS {
+		for _, name := range names {
+			_, ok := FUNCTIONS[name]
+			assert.True(t, ok, name)
+			grouped[name] = true
+		}
+	}
+	for name := range FUNCTIONS {
+		assert.True(t, grouped[name], name)
+	}
+}
@@ -0,0 +1,241 @@
+package funny
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Reporter writes the results of running tests or scripts in some format
+type Reporter interface {
+	Report(w io.Writer, results []TestResult) error
+}
+
+// REPORTERS the reporters can be chosen by name like funny test --report junit=out.xml,
+// embedders can add their own
+var REPORTERS = map[string]Reporter{
+	"junit": JUnitReporter{},
+	"tap":   TAPReporter{},
+	"json":  JSONReporter{},
+}
+
+// ReporterNames the names of REPORTERS in sorted order
+func ReporterNames() []string {
+	names := make([]string, 0, len(REPORTERS))
+	for name := range REPORTERS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// location file:line of the position, which is relative to the file of the test if it is
+// in the same directory
+func location(result TestResult) string {
+	pos := result.ErrorPosition()
+	file := pos.File
+	if file == "" {
+		file = result.File
+	} else if abs, err := filepath.Abs(result.File); err == nil && filepath.Dir(abs) == filepath.Dir(file) {
+		file = filepath.Join(filepath.Dir(result.File), filepath.Base(file))
+	}
+	return fmt.Sprintf("%s:%d", file, pos.Line+1)
+}
+
+// JUnitReporter writes JUnit XML, one test suite for each file
+type JUnitReporter struct{}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// Report write results as JUnit XML
+func (JUnitReporter) Report(w io.Writer, results []TestResult) error {
+	suites := junitSuites{}
+	index := make(map[string]int)
+	var total float64
+	times := make(map[string]float64)
+	for _, result := range results {
+		at, ok := index[result.File]
+		if !ok {
+			at = len(suites.Suites)
+			index[result.File] = at
+			suites.Suites = append(suites.Suites, junitSuite{
+				Name: result.File,
+			})
+		}
+		suite := &suites.Suites[at]
+		c := junitCase{
+			Name:      result.Name,
+			ClassName: strings.TrimSuffix(filepath.ToSlash(result.File), filepath.Ext(result.File)),
+			File:      result.File,
+			Line:      result.Position.Line + 1,
+			Time:      seconds(result.Duration.Seconds()),
+			SystemOut: result.Output,
+		}
+		switch result.Status {
+		case TestFailed:
+			c.Failure = &junitMessage{
+				Message: result.Message(),
+				Type:    "error",
+				Text:    failureText(result),
+			}
+			suite.Failures++
+			suites.Failures++
+		case TestSkipped:
+			c.Skipped = &junitMessage{
+				Message: result.Message(),
+			}
+			suite.Skipped++
+			suites.Skipped++
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests++
+		suites.Tests++
+		times[result.File] += result.Duration.Seconds()
+		total += result.Duration.Seconds()
+	}
+	for at := range suites.Suites {
+		suites.Suites[at].Time = seconds(times[suites.Suites[at].Name])
+	}
+	suites.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// failureText the location, message and source line of the failure
+func failureText(result TestResult) string {
+	text := fmt.Sprintf("%s: %s", location(result), result.Message())
+	if result.Source != "" {
+		text += "\n    " + result.Source
+	}
+	return text
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+// TAPReporter writes TAP version 13, with the details of failures in YAML blocks
+type TAPReporter struct{}
+
+// Report write results as TAP
+func (TAPReporter) Report(w io.Writer, results []TestResult) error {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "TAP version 13\n1..%d\n", len(results))
+	for index, result := range results {
+		status := "ok"
+		if result.Status == TestFailed {
+			status = "not ok"
+		}
+		fmt.Fprintf(sb, "%s %d - %s: %s", status, index+1, result.File, result.Name)
+		if result.Status == TestSkipped {
+			fmt.Fprintf(sb, " # SKIP %s", result.Message())
+		}
+		sb.WriteString("\n")
+		fmt.Fprintf(sb, "  ---\n  duration_ms: %.3f\n", float64(result.Duration.Microseconds())/1000)
+		if result.Status == TestFailed {
+			fmt.Fprintf(sb, "  message: %s\n  at: %s\n", yamlString(result.Message()), yamlString(location(result)))
+			if result.Source != "" {
+				fmt.Fprintf(sb, "  source: %s\n", yamlString(result.Source))
+			}
+		}
+		if result.Output != "" {
+			sb.WriteString("  output: |\n")
+			for _, line := range strings.Split(strings.TrimRight(result.Output, "\n"), "\n") {
+				fmt.Fprintf(sb, "    %s\n", line)
+			}
+		}
+		sb.WriteString("  ...\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// yamlString quote s as a YAML string, JSON strings are valid YAML
+func yamlString(s string) string {
+	bts, _ := json.Marshal(s)
+	return string(bts)
+}
+
+// JSONReporter writes a JSON array of the results
+type JSONReporter struct{}
+
+type jsonResult struct {
+	File     string     `json:"file"`
+	Name     string     `json:"name"`
+	Line     int        `json:"line"`
+	Status   TestStatus `json:"status"`
+	Duration float64    `json:"duration"`
+	Message  string     `json:"message,omitempty"`
+	Location string     `json:"location,omitempty"`
+	Source   string     `json:"source,omitempty"`
+	Output   string     `json:"output,omitempty"`
+}
+
+// Report write results as JSON, durations are in seconds
+func (JSONReporter) Report(w io.Writer, results []TestResult) error {
+	items := make([]jsonResult, 0, len(results))
+	for _, result := range results {
+		item := jsonResult{
+			File:     result.File,
+			Name:     result.Name,
+			Line:     result.Position.Line + 1,
+			Status:   result.Status,
+			Duration: result.Duration.Seconds(),
+			Message:  result.Message(),
+			Source:   result.Source,
+			Output:   result.Output,
+		}
+		if result.Err != nil {
+			item.Location = location(result)
+		}
+		items = append(items, item)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(items)
+}
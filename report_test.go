@@ -0,0 +1,94 @@
+package funny
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reportResults() []TestResult {
+	return []TestResult{
+		{
+			File:     "math_test.funny",
+			Name:     "test_add",
+			Position: Position{Line: 2},
+			Status:   TestPassed,
+			Duration: 1500 * time.Microsecond,
+			Output:   "3\n",
+		},
+		{
+			File:     "math_test.funny",
+			Name:     "adds base",
+			Position: Position{Line: 6},
+			Status:   TestFailed,
+			Duration: time.Millisecond,
+			Err: &FunnyRuntimeError{
+				Postion: Position{File: "math_test.funny", Line: 8, Col: 2},
+				Msg:     "assertion failed: base plus one",
+			},
+			Source: "assert(x == 12, 'base plus one')",
+		},
+		{
+			File:     "math_test.funny",
+			Name:     "later",
+			Position: Position{Line: 11},
+			Status:   TestSkipped,
+			Err: &FunnyRuntimeError{
+				Msg: "not ready",
+				Err: ErrSkip,
+			},
+		},
+	}
+}
+
+func TestJUnitReporter(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.NoError(t, JUnitReporter{}.Report(out, reportResults()))
+	var suites junitSuites
+	assert.NoError(t, xml.Unmarshal(out.Bytes(), &suites))
+	assert.Equal(t, 3, suites.Tests)
+	assert.Equal(t, 1, suites.Failures)
+	assert.Equal(t, 1, suites.Skipped)
+	assert.Equal(t, 1, len(suites.Suites))
+	cases := suites.Suites[0].Cases
+	assert.Equal(t, 3, len(cases))
+	assert.Equal(t, "0.002", cases[0].Time)
+	assert.Equal(t, "3\n", cases[0].SystemOut)
+	assert.Equal(t, 7, cases[1].Line)
+	assert.Equal(t, "assertion failed: base plus one", cases[1].Failure.Message)
+	assert.True(t, strings.HasPrefix(cases[1].Failure.Text, "math_test.funny:9: "))
+	assert.Equal(t, "not ready", cases[2].Skipped.Message)
+}
+
+func TestTAPReporter(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.NoError(t, TAPReporter{}.Report(out, reportResults()))
+	text := out.String()
+	assert.True(t, strings.HasPrefix(text, "TAP version 13\n1..3\n"))
+	assert.Contains(t, text, "ok 1 - math_test.funny: test_add\n")
+	assert.Contains(t, text, "  output: |\n    3\n")
+	assert.Contains(t, text, "not ok 2 - math_test.funny: adds base\n")
+	assert.Contains(t, text, "  at: \"math_test.funny:9\"\n")
+	assert.Contains(t, text, "ok 3 - math_test.funny: later # SKIP not ready\n")
+}
+
+func TestJSONReporter(t *testing.T) {
+	out := new(bytes.Buffer)
+	assert.NoError(t, JSONReporter{}.Report(out, reportResults()))
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &items))
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, "pass", items[0]["status"])
+	assert.Equal(t, 0.0015, items[0]["duration"])
+	assert.Equal(t, "3\n", items[0]["output"])
+	assert.Equal(t, "fail", items[1]["status"])
+	assert.Equal(t, "assertion failed: base plus one", items[1]["message"])
+	assert.Equal(t, "math_test.funny:9", items[1]["location"])
+	assert.Equal(t, float64(7), items[1]["line"])
+	assert.Equal(t, "skip", items[2]["status"])
+}
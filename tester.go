@@ -0,0 +1,242 @@
+package funny
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestFileSuffix the suffix of the files test functions are found in
+const TestFileSuffix = "_test.funny"
+
+// ErrSkip the error raised by skip() to skip the running test
+var ErrSkip = errors.New("test skipped")
+
+// TestStatus the outcome of one test
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "pass"
+	TestFailed  TestStatus = "fail"
+	TestSkipped TestStatus = "skip"
+)
+
+// TestCase one test of a test file, which is a top level function named like test_add
+// without parameters, or a block like test('adds numbers') { }
+type TestCase struct {
+	Name     string
+	Position Position
+	Body     *Block
+}
+
+// TestResult the outcome of running one test
+type TestResult struct {
+	File     string
+	Name     string
+	Position Position
+	Status   TestStatus
+	Duration time.Duration
+	// Err why the test failed or was skipped
+	Err error
+	// Source the line of code the test failed at if it is in the test file
+	Source string
+	// Output what the test wrote to Stdout
+	Output string
+}
+
+// Message the message of Err, empty if the test passed
+func (r TestResult) Message() string {
+	var fre *FunnyRuntimeError
+	switch {
+	case r.Err == nil:
+		return ""
+	case errors.As(r.Err, &fre):
+		return fre.Msg
+	}
+	return strings.TrimRight(r.Err.Error(), "\n")
+}
+
+// ErrorPosition where Err happened, or the position of the test if it is unknown
+func (r TestResult) ErrorPosition() Position {
+	var fre *FunnyRuntimeError
+	if errors.As(r.Err, &fre) {
+		return fre.Postion
+	}
+	return r.Position
+}
+
+// TestRunner runs the tests of test files, each test is run by its own interpreter after
+// the statements of the file which are not tests, so tests never affect each other
+type TestRunner struct {
+	// New create the interpreter of one test, NewFunny() if it is nil
+	New func() *Funny
+	// Filter only the tests whose names match it are run if it is not nil
+	Filter *regexp.Regexp
+}
+
+// FindTestFiles the test files in paths in sorted order, directories are searched
+// recursively except the hidden ones, and files are used as they are
+func FindTestFiles(paths ...string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(file string) {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(root)
+			continue
+		}
+		err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if file != root && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(file, TestFileSuffix) {
+				add(file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ParseTests split the statements of a test file into the tests and the setup ones run
+// before every test
+func ParseTests(block *Block) (setup *Block, tests []TestCase) {
+	setup = &Block{
+		Position: block.Position,
+		Type:     block.Type,
+	}
+	for _, item := range block.Statements {
+		if f, ok := item.(*Function); ok {
+			if f.Name == "test" && len(f.Parameters) == 1 {
+				if name, ok := f.Parameters[0].(*Literal); ok {
+					if name, ok := name.Value.(string); ok {
+						tests = append(tests, TestCase{
+							Name:     name,
+							Position: f.Position,
+							Body:     f.Body,
+						})
+						continue
+					}
+				}
+			}
+			if strings.HasPrefix(f.Name, "test_") && len(f.Parameters) == 0 {
+				tests = append(tests, TestCase{
+					Name:     f.Name,
+					Position: f.Position,
+					Body:     f.Body,
+				})
+			}
+		}
+		setup.Statements = append(setup.Statements, item)
+	}
+	return setup, tests
+}
+
+// RunFile run the tests of file filename, the error is returned if it can not be read or
+// parsed
+func (r *TestRunner) RunFile(ctx context.Context, filename string) ([]TestResult, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, err
+	}
+	// imports are read when parsing, so they are limited like the files read by the tests
+	block, err := r.newFunny().NewParser(data, abs).Parse()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	setup, tests := ParseTests(block)
+	var results []TestResult
+	for _, test := range tests {
+		if r.Filter != nil && !r.Filter.MatchString(test.Name) {
+			continue
+		}
+		result := r.run(ctx, setup, test)
+		result.File = filename
+		var fre *FunnyRuntimeError
+		if errors.As(result.Err, &fre) && fre.Postion.File == abs && fre.Postion.Line < len(lines) {
+			result.Source = strings.TrimSpace(lines[fre.Postion.Line])
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// newFunny create the interpreter of one test
+func (r *TestRunner) newFunny() *Funny {
+	if r.New != nil {
+		return r.New()
+	}
+	return NewFunny()
+}
+
+// run run test after setup by a new interpreter
+func (r *TestRunner) run(ctx context.Context, setup *Block, test TestCase) TestResult {
+	fn := r.newFunny()
+	// a skip function of the script takes precedence
+	_ = fn.RegisterFunction("skip", Skip)
+	output := new(bytes.Buffer)
+	fn.Stdout = output
+	start := time.Now()
+	_, err := fn.RunContext(ctx, &Program{Statements: setup})
+	if err == nil {
+		_, err = fn.RunContext(ctx, &Program{Statements: test.Body})
+	}
+	result := TestResult{
+		Name:     test.Name,
+		Position: test.Position,
+		Status:   TestPassed,
+		Duration: time.Since(start),
+		Err:      err,
+		Output:   output.String(),
+	}
+	switch {
+	case errors.Is(err, ErrSkip):
+		result.Status = TestSkipped
+	case err != nil:
+		result.Status = TestFailed
+	}
+	return result
+}
+
+// Skip skip(reason) skip the running test
+func Skip(fn *Funny, args []Value) Value {
+	msg := "skipped"
+	if len(args) > 0 {
+		msg = Str(fn, args[:1]).(string)
+	}
+	panic(&FunnyRuntimeError{
+		Postion: fn.Current,
+		Msg:     msg,
+		Err:     ErrSkip,
+	})
+}
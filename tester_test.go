@@ -0,0 +1,92 @@
+package funny
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mathTests = `
+base = 10
+
+test_add() {
+  assert(1 + 2 == 3)
+}
+
+test('adds base') {
+  x = base + 1
+  assert(x == 12, 'base plus one')
+}
+
+test('later') {
+  skip('not ready')
+}
+
+test_change() {
+  base = 20
+  echoln(base)
+}
+
+test('isolated') {
+  assert(base == 10)
+}
+
+helper(a) {
+  return a
+}
+`
+
+func TestRunTests(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "math_test.funny")
+	assert.Nil(t, os.WriteFile(file, []byte(mathTests), 0644))
+
+	results, err := new(TestRunner).RunFile(context.Background(), file)
+	assert.Nil(t, err)
+	var names []string
+	var statuses []TestStatus
+	for _, result := range results {
+		names = append(names, result.Name)
+		statuses = append(statuses, result.Status)
+		assert.Equal(t, file, result.File)
+	}
+	assert.Equal(t, []string{"test_add", "adds base", "later", "test_change", "isolated"}, names)
+	assert.Equal(t, []TestStatus{TestPassed, TestFailed, TestSkipped, TestPassed, TestPassed}, statuses)
+
+	failure := results[1]
+	assert.Equal(t, "assertion failed: base plus one", failure.Err.(*FunnyRuntimeError).Msg)
+	assert.Equal(t, 9, failure.Err.(*FunnyRuntimeError).Postion.Line)
+	assert.Equal(t, "assert(x == 12, 'base plus one')", failure.Source)
+	assert.Equal(t, 7, failure.Position.Line)
+	assert.True(t, errors.Is(results[2].Err, ErrSkip))
+	assert.Equal(t, "not ready", results[2].Message())
+	assert.Equal(t, "20\n", results[3].Output)
+
+	results, err = (&TestRunner{Filter: regexp.MustCompile("^test_")}).RunFile(context.Background(), file)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+}
+
+func TestFindTestFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a_test.funny", "b.funny", "sub/c_test.funny", ".git/d_test.funny"} {
+		file = filepath.Join(dir, file)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.Nil(t, os.WriteFile(file, nil, 0644))
+	}
+	files, err := FindTestFiles(dir, filepath.Join(dir, "b.funny"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a_test.funny"),
+		filepath.Join(dir, "b.funny"),
+		filepath.Join(dir, "sub", "c_test.funny"),
+	}, files)
+
+	_, err = FindTestFiles(filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
@@ -19,12 +19,16 @@ const (
 	GTE         = ">="
 	LTE         = "<="
 	NOTEQ       = "!="
+	BANG        = "!"
 	COMMA       = ","
+	COLON       = ":"
 	DOT         = "."
 	EOF         = "EOF"
 	INT         = "INT"
+	FLOAT       = "FLOAT"
 	NAME        = "NAME"
 	STRING      = "STRING"
+	TEMPLATE    = "TEMPLATE"
 
 	IF       = "if"
 	ELSE     = "else"
@@ -35,10 +39,15 @@ const (
 	IN       = "in"
 	NIL      = "nil"
 	NOT      = "not"
+	NOTIN    = "not in"
 	OR       = "or"
 	RETURN   = "return"
 	BREAK    = "break"
 	CONTINUE = "continue"
+	TRY      = "try"
+	CATCH    = "catch"
+	FINALLY  = "finally"
+	FN       = "fn"
 
 	NEW_LINE = "\\n"
 	COMMENT  = "comment"
@@ -58,4 +67,7 @@ var Keywords = map[string]string{
 	"true":     "true",
 	"break":    "break",
 	"continue": "continue",
+	"try":      "try",
+	"catch":    "catch",
+	"finally":  "finally",
 }